@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/helper"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+	f := batchCmd.Flags()
+	f.IntVarP(&batchJobs, "jobs", "j", 4, "Number of repositories processed concurrently")
+	f.StringVarP(&batchOutputFile, "output", "o", "", "File the records of all the repositories are written to, one JSON object per line, defaults to the standard output")
+	f.StringVar(&batchOutputDir, "output-dir", "", "Directory a JSON file per repository, named after its id, is written to, instead of one combined output")
+}
+
+var (
+	batchJobs       int
+	batchOutputFile string
+	batchOutputDir  string
+
+	batchCmd = &cobra.Command{
+		Use:   "batch [file]",
+		Short: "Computes the churn metrics of many repositories",
+		Long: `batch reads a list of repositories from the given file, or from the standard input
+if there is none or it is "-", and computes their churn concurrently. Every line holds a
+repository URL or path, optionally followed by options:
+
+  https://github.com/org/api id=api range=v1.0.. paths=src,docs
+  /path/to/local/repo
+
+  id      the id the records of the repository are tagged with, by default the host
+          and path of the URL or the name of the directory
+  range   the revision range "from..to", by default the history of HEAD
+  paths   the comma separated paths the churn is restricted to
+
+Empty lines and lines starting with # are ignored. Every record is the churn of a commit
+with a Repo field holding the repository id. A repository that fails does not stop the
+others, the failures are summed up at the end.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			in := os.Stdin
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				CheckIfError(err)
+				defer f.Close()
+				in = f
+			}
+			repos, err := readBatch(in)
+			CheckIfError(err)
+			if batchOutputDir != "" {
+				CheckIfError(checkBatchFileNames(repos))
+			}
+
+			out, err := newBatchOutput()
+			CheckIfError(err)
+			ctx, cancel := runContext()
+			failures := runBatch(ctx, repos, out)
+			cancel()
+			CheckIfError(out.close())
+
+			if len(failures) == 0 {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "%d of %d repositories failed:\n", len(failures), len(repos))
+			w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+			for _, f := range failures {
+				fmt.Fprintf(w, "%s\t%s\n", f.repo.ID, f.err)
+			}
+			w.Flush()
+			os.Exit(ExitError)
+		},
+	}
+)
+
+// BatchRepo is a repository of a batch and its options.
+type BatchRepo struct {
+	ID    string
+	URL   string
+	Range string
+	Paths []string
+}
+
+// BatchRecord is the churn of a commit of a repository of a batch.
+type BatchRecord struct {
+	Repo string
+	metrics.Churn
+}
+
+type batchFailure struct {
+	repo BatchRepo
+	err  error
+}
+
+// readBatch reads the repositories of a batch, one per line.
+func readBatch(in io.Reader) ([]BatchRepo, error) {
+	var repos []BatchRepo
+	ids := make(map[string]int)
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		repo := BatchRepo{URL: fields[0]}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("line %d: option %q is not key=value", n, field)
+			}
+			switch kv[0] {
+			case "id":
+				repo.ID = kv[1]
+			case "range":
+				repo.Range = kv[1]
+			case "paths":
+				repo.Paths = strings.Split(kv[1], ",")
+			default:
+				return nil, fmt.Errorf("line %d: unknown option %q, must be id, range or paths", n, kv[0])
+			}
+		}
+		if repo.ID == "" {
+			repo.ID = batchID(repo.URL)
+		}
+		if first, ok := ids[repo.ID]; ok {
+			return nil, fmt.Errorf("line %d: repository id %q is already used on line %d, set another one with id=", n, repo.ID, first)
+		}
+		ids[repo.ID] = n
+		repos = append(repos, repo)
+	}
+	return repos, scanner.Err()
+}
+
+// batchID returns the default id of the repository at url: the host and path
+// of a remote URL, the name of the directory of a local one.
+func batchID(url string) string {
+	if key, err := metrics.NormalizeURL(url); err == nil && !strings.HasPrefix(key, "file://") {
+		return key[strings.Index(key, "://")+3:]
+	}
+	if abs, err := fp.Abs(url); err == nil {
+		url = abs
+	}
+	return fp.Base(url)
+}
+
+// batchFileName returns the name of the file of --output-dir the records of
+// the repository id are written to.
+func batchFileName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == ':' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, id) + ".json"
+}
+
+// checkBatchFileNames returns an error if the records of two repositories
+// would be written to the same file, ignoring case for the filesystems that
+// do.
+func checkBatchFileNames(repos []BatchRepo) error {
+	ids := make(map[string]string)
+	for _, repo := range repos {
+		name := strings.ToLower(batchFileName(repo.ID))
+		if id, ok := ids[name]; ok {
+			return fmt.Errorf("repository ids %q and %q are both written to %s, set another one with id=", id, repo.ID,
+				batchFileName(repo.ID))
+		}
+		ids[name] = repo.ID
+	}
+	return nil
+}
+
+// runBatch computes the churn of the repositories, batchJobs at a time, and
+// returns the failures in the order of the repositories.
+func runBatch(ctx context.Context, repos []BatchRepo, out *batchOutput) []batchFailure {
+	// the progress of the repositories would interleave, only the number of
+	// repositories done is reported
+	var report metrics.ProgressFunc
+	if reporter != nil {
+		report = reporter.report
+	}
+	repoCtx := metrics.WithProgress(ctx, nil)
+
+	var (
+		mu       sync.Mutex
+		failures []batchFailure
+		done     int
+		start    = time.Now()
+		wg       sync.WaitGroup
+		queue    = make(chan BatchRepo)
+	)
+	progress := func() {
+		if report == nil {
+			return
+		}
+		ev := metrics.ProgressEvent{Phase: "repositories", Done: done, Total: len(repos), Elapsed: time.Since(start)}
+		if done > 0 {
+			ev.ETA = time.Duration(float64(ev.Elapsed) / float64(done) * float64(len(repos)-done))
+		}
+		report(ev)
+	}
+	progress()
+	for i := 0; i < batchJobs || i == 0; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range queue {
+				err := runBatchRepo(repoCtx, repo, out)
+				if err != nil {
+					helper.Info("repository failed", "repo", repo.ID, "error", err)
+				}
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, batchFailure{repo, err})
+				}
+				done++
+				progress()
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, repo := range repos {
+		queue <- repo
+	}
+	close(queue)
+	wg.Wait()
+
+	index := make(map[string]int, len(repos))
+	for i, repo := range repos {
+		index[repo.ID] = i
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return index[failures[i].repo.ID] < index[failures[j].repo.ID]
+	})
+	return failures
+}
+
+func runBatchRepo(ctx context.Context, repo BatchRepo, out *batchOutput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r, err := metrics.OpenRepo(ctx, repo.URL)
+	if err != nil {
+		return err
+	}
+	from, to, err := metrics.ResolveRange(r, repo.Range)
+	if err != nil {
+		return err
+	}
+	churns, err := metrics.Churns(ctx, to, &metrics.ChurnOptions{Paths: repo.Paths, From: from})
+	if err != nil {
+		return err
+	}
+	return out.write(repo, churns)
+}
+
+// batchOutput writes the records of the repositories, either to a combined
+// output or to a file per repository.
+type batchOutput struct {
+	mu  sync.Mutex
+	dir string
+	w   *bufio.Writer
+	f   *os.File
+}
+
+func newBatchOutput() (*batchOutput, error) {
+	if batchOutputDir != "" {
+		if batchOutputFile != "" {
+			return nil, fmt.Errorf("--output and --output-dir cannot be used together")
+		}
+		return &batchOutput{dir: batchOutputDir}, os.MkdirAll(batchOutputDir, 0755)
+	}
+	out := &batchOutput{w: bufio.NewWriter(os.Stdout)}
+	if batchOutputFile != "" {
+		f, err := os.Create(batchOutputFile)
+		if err != nil {
+			return nil, err
+		}
+		out.f = f
+		out.w = bufio.NewWriter(f)
+	}
+	return out, nil
+}
+
+func (o *batchOutput) write(repo BatchRepo, churns []metrics.Churn) error {
+	records := make([]BatchRecord, len(churns))
+	for i, churn := range churns {
+		records[i] = BatchRecord{Repo: repo.ID, Churn: churn}
+	}
+	if o.dir != "" {
+		data, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(fp.Join(o.dir, batchFileName(repo.ID)))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	enc := json.NewEncoder(o.w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return o.w.Flush()
+}
+
+func (o *batchOutput) close() error {
+	if o.f == nil {
+		return nil
+	}
+	return o.f.Close()
+}
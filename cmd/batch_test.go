@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadBatch(t *testing.T) {
+	in := "# repositories\n\nhttps://github.com/org/api id=api range=v1.0.. paths=src,docs\n" +
+		"  https://github.com/org/web.git\n/path/to/tools\n"
+	got, err := readBatch(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BatchRepo{
+		{ID: "api", URL: "https://github.com/org/api", Range: "v1.0..", Paths: []string{"src", "docs"}},
+		{ID: "github.com/org/web", URL: "https://github.com/org/web.git"},
+		{ID: "tools", URL: "/path/to/tools"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("repositories %+v, want %+v", got, want)
+	}
+
+	for _, in := range []string{
+		"https://github.com/org/api paths\n",
+		"https://github.com/org/api branch=main\n",
+		"https://github.com/org/api\nhttps://github.com/org/api.git\n",
+		"/path/to/tools\n/other/tools\n",
+		"https://github.com/org/api id=x\n/path/to/x\n",
+	} {
+		if repos, err := readBatch(strings.NewReader(in)); err == nil {
+			t.Errorf("readBatch(%q) = %+v, want an error", in, repos)
+		}
+	}
+}
+
+func TestCheckBatchFileNames(t *testing.T) {
+	tests := []struct {
+		ids []string
+		ok  bool
+	}{
+		{[]string{"github.com/org/api", "github.com/org/web", "api"}, true},
+		{[]string{"github.com/org/api", "github.com_org_api"}, false},
+		{[]string{"host:api", "host/api"}, false},
+		{[]string{"API", "api"}, false},
+	}
+	for _, test := range tests {
+		var repos []BatchRepo
+		for _, id := range test.ids {
+			repos = append(repos, BatchRepo{ID: id})
+		}
+		if err := checkBatchFileNames(repos); (err == nil) != test.ok {
+			t.Errorf("checkBatchFileNames(%q) = %v, want ok %v", test.ids, err, test.ok)
+		}
+	}
+}
+
+// A repository that fails does not stop the others, and the failures are in
+// the order of the repositories.
+func TestRunBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	good := fp.Join(dir, "good")
+	r, err := git.PlainInit(good, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fp.Join(good, "a.txt"), []byte("a1\na2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "alice", Email: "alice", When: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := w.Commit("add a", &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(jobs int) { batchJobs = jobs }(batchJobs)
+	batchJobs = 3
+	repos := []BatchRepo{
+		{ID: "missing", URL: fp.Join(dir, "missing")},
+		{ID: "good", URL: good},
+		{ID: "bad-range", URL: good, Range: "nope..HEAD"},
+		{ID: "empty", URL: fp.Join(dir, "empty")},
+	}
+	if err := os.Mkdir(fp.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	failures := runBatch(context.Background(), repos, &batchOutput{w: bufio.NewWriter(&buf)})
+
+	var ids []string
+	for _, f := range failures {
+		ids = append(ids, f.repo.ID)
+	}
+	if want := []string{"missing", "bad-range", "empty"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("failures %q, want %q", ids, want)
+	}
+	var record BatchRecord
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output %q: %v", buf.String(), err)
+	}
+	if record.Repo != "good" || record.CommitAuthor != "alice" {
+		t.Errorf("record of %s by %s, want good by alice", record.Repo, record.CommitAuthor)
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	f := cachePruneCmd.Flags()
+	f.DurationVar(&cacheOlderThan, "older-than", 30*24*time.Hour, "Removes the repositories not fetched for longer than this")
+	f.BoolVar(&cacheAll, "all", false, "Removes all the repositories")
+}
+
+var (
+	cacheOlderThan time.Duration
+	cacheAll       bool
+
+	cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Lists and prunes the repositories in the --cache-dir directory",
+	}
+
+	cacheListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Lists the cached repositories, the least recently fetched first",
+		Run: func(cmd *cobra.Command, args []string) {
+			CheckIfError(checkCacheDir())
+			entries, err := metrics.ListCache(metrics.CacheDir)
+			CheckIfError(err)
+			printCacheEntries(entries)
+		},
+	}
+
+	cachePruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Removes the cached repositories that were not fetched recently",
+		Run: func(cmd *cobra.Command, args []string) {
+			CheckIfError(checkCacheDir())
+			if cacheAll {
+				cacheOlderThan = 0
+			}
+			entries, err := metrics.PruneCache(metrics.CacheDir, cacheOlderThan)
+			printCacheEntries(entries)
+			CheckIfError(err)
+		},
+	}
+)
+
+func checkCacheDir() error {
+	if metrics.CacheDir == "" {
+		return errors.New("the --cache-dir flag is required")
+	}
+	return nil
+}
+
+func printCacheEntries(entries []metrics.CacheEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tLAST FETCH\tSIZE (MB)\tDIRECTORY")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%.1f\t%s\n", e.URL, e.LastFetch.Format(time.RFC3339),
+			float64(e.Size)/(1<<20), e.Dir)
+	}
+	w.Flush()
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(couplingCmd)
+	f := couplingCmd.Flags()
+	f.StringVar(&couplingRev, "rev", "HEAD", "Revision whose history is analyzed")
+	f.StringVarP(&couplingOptions.Group, "group-by", "g", metrics.GroupFile,
+		"Pairs to compute the co-change of: "+strings.Join(metrics.CouplingGroups, ", "))
+	f.IntVar(&couplingOptions.ModuleDepth, "module-depth", 0, "Makes the modules the directories at this depth instead of the ones with a manifest (go.mod, package.json, ...)")
+	f.StringVar(&couplingSince, "since", "", "Leaves out the commits authored before this date, e.g. 2020-01-31")
+	f.StringVar(&couplingUntil, "until", "", "Leaves out the commits authored after this date")
+	f.IntVar(&couplingOptions.MaxFiles, "max-files", 50, "Leaves out the commits changing more files, 0 for no limit")
+	f.IntVar(&couplingOptions.MinCount, "min-count", 2, "Leaves out the pairs changed together fewer times")
+	f.IntVar(&couplingTop, "top", 50, "Number of pairs printed, 0 for all")
+	f.BoolVar(&couplingJSON, "json", false, "Prints the co-change of the pairs as JSON")
+}
+
+var (
+	couplingRev     string
+	couplingOptions metrics.CouplingOptions
+	couplingSince   string
+	couplingUntil   string
+	couplingTop     int
+	couplingJSON    bool
+
+	couplingCmd = &cobra.Command{
+		Use:   "coupling",
+		Short: "Reports the files, directories or modules often changed together",
+		Long: `coupling computes the logical coupling of the pairs of files, directories or modules
+changed together by the commits of the history of the given revision: the number of
+co-changes, their support (the share of all the commits), the confidence in both
+directions (the probability that a commit changing one changes the other), and the
+degree of coupling (the share of the commits changing either that change both), by
+which the pairs are ranked. Merges and commits changing too many files are left out.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			var err error
+			couplingOptions.Since, err = parseDate(couplingSince)
+			CheckIfError(err)
+			couplingOptions.Until, err = parseUntil(couplingUntil)
+			CheckIfError(err)
+			couplingOptions.Prefix = filepath
+
+			ctx, cancel := runContext()
+			defer cancel()
+			commitObj, err := metrics.CommitAt(ctx, repoUrl, couplingRev)
+			CheckIfError(err)
+			result, err := metrics.Couplings(ctx, commitObj, couplingOptions)
+			CheckIfError(err)
+			if couplingTop > 0 && len(result.Pairs) > couplingTop {
+				result.Pairs = result.Pairs[:couplingTop]
+			}
+
+			if couplingJSON {
+				data, err := json.MarshalIndent(result, "", "  ")
+				CheckIfError(err)
+				fmt.Println(string(data))
+				return
+			}
+			fmt.Printf("%d commits, %d left out with more than %d files\n\n", result.Commits, result.Skipped,
+				couplingOptions.MaxFiles)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "A\tB\tCO-CHANGES\tSUPPORT\tCONF A>B\tCONF B>A\tDEGREE")
+			for _, p := range result.Pairs {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\t%.1f%%\t%.1f%%\t%.1f%%\n", p.A, p.B, p.Count, p.Support*100,
+					p.ConfidenceAB*100, p.ConfidenceBA*100, p.Degree*100)
+			}
+			w.Flush()
+		},
+	}
+)
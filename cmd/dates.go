@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// parseDate parses a date, as 2006-01-02 or RFC 3339. The empty string is the
+// zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, must be 2006-01-02 or RFC 3339", s)
+}
+
+// parseUntil parses the end of a time window like parseDate, a date without a
+// time being the end of the day.
+func parseUntil(s string) (time.Time, error) {
+	t, err := parseDate(s)
+	if err == nil && len(s) == len("2006-01-02") {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, err
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/linediff"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	rootCmd.AddCommand(diffBenchCmd)
+	f := diffBenchCmd.Flags()
+	f.StringVar(&diffBenchRev, "rev", "HEAD", "Revision whose history the file revisions are taken from")
+	f.IntVar(&diffBenchCommits, "max-commits", 500, "Number of commits whose modified files are diffed")
+	f.IntVar(&diffBenchRounds, "rounds", 3, "Number of times every pair of file revisions is diffed by every algorithm")
+}
+
+var (
+	diffBenchRev     string
+	diffBenchCommits int
+	diffBenchRounds  int
+
+	diffBenchCmd = &cobra.Command{
+		Use:   "diff-bench",
+		Short: "Benchmarks the line diff algorithms on the files of a repository",
+		Long: `diff-bench diffs the files modified by the last commits of the history of a revision
+with every line diff algorithm, and reports the time taken, the lines inserted and
+deleted (the fewer, the tighter the diff) and how many diffs differ from Myers'.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			ctx, cancel := runContext()
+			defer cancel()
+			c, err := metrics.CommitAt(ctx, repoUrl, diffBenchRev)
+			CheckIfError(err)
+			pairs, err := filePairs(c, diffBenchCommits)
+			CheckIfError(err)
+			if len(pairs) == 0 {
+				fmt.Println("No modified files to diff")
+				return
+			}
+
+			var lines int
+			for _, p := range pairs {
+				lines += len(p[0]) + len(p[1])
+			}
+			if diffBenchRounds < 1 {
+				diffBenchRounds = 1
+			}
+			reference := make([][]linediff.Hunk, len(pairs))
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ALGORITHM\tPAIRS\tLINES\tTIME\tPER PAIR\tEDITED LINES\tDIFFERENT FROM MYERS")
+			for _, alg := range linediff.Algorithms {
+				start := time.Now()
+				var hunks [][]linediff.Hunk
+				for round := 0; round < diffBenchRounds; round++ {
+					hunks = hunks[:0]
+					for _, p := range pairs {
+						hunks = append(hunks, alg.DoLines(p[0], p[1]))
+					}
+				}
+				elapsed := time.Since(start) / time.Duration(diffBenchRounds)
+
+				edited, different := 0, 0
+				for i, h := range hunks {
+					edited += editedLines(h)
+					if alg == linediff.Myers {
+						reference[i] = h
+					} else if !sameHunks(h, reference[i]) {
+						different++
+					}
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%d\t%d\n", alg, len(pairs), lines, elapsed.Round(time.Microsecond),
+					(elapsed / time.Duration(len(pairs))).Round(time.Nanosecond), edited, different)
+			}
+			w.Flush()
+		},
+	}
+)
+
+// filePairs returns the contents, split into lines, before and after every
+// file modified by the last commits of the history of c but the merges.
+func filePairs(c *object.Commit, commits int) ([][2][]string, error) {
+	var pairs [][2][]string
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	defer iter.Close()
+	for n := 0; n < commits; n++ {
+		commit, err := iter.Next()
+		if err != nil {
+			break
+		}
+		if commit.NumParents() != 1 {
+			continue
+		}
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		changes, err := diffTrees(parent, commit)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			if action, _ := change.Action(); action != merkletrie.Modify {
+				continue
+			}
+			from, to, err := change.Files()
+			if err != nil {
+				return nil, err
+			}
+			if binary, _ := from.IsBinary(); binary {
+				continue
+			}
+			src, err := from.Contents()
+			if err != nil {
+				return nil, err
+			}
+			dst, err := to.Contents()
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, [2][]string{linediff.Split(src), linediff.Split(dst)})
+		}
+	}
+	return pairs, nil
+}
+
+func diffTrees(from, to *object.Commit) (object.Changes, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return object.DiffTree(fromTree, toTree)
+}
+
+func editedLines(hunks []linediff.Hunk) int {
+	n := 0
+	for _, h := range hunks {
+		if h.Op != linediff.Equal {
+			n += len(h.Lines)
+		}
+	}
+	return n
+}
+
+func sameHunks(a, b []linediff.Hunk) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Op != b[i].Op || len(a[i].Lines) != len(b[i].Lines) {
+			return false
+		}
+	}
+	return true
+}
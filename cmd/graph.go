@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+	f := graphCmd.Flags()
+	f.StringVar(&graphRange, "range", "HEAD", "Revision range \"from..to\" whose interactive churn builds the graph")
+	f.StringVar(&graphFormat, "format", "dot", "Output format: dot, graphml or json")
+	f.StringVarP(&graphOutput, "output", "o", "", "File the graph is written to, defaults to the standard output")
+}
+
+var (
+	graphRange  string
+	graphFormat string
+	graphOutput string
+
+	graphCmd = &cobra.Command{
+		Use:   "graph",
+		Short: "Exports the author interaction graph built from the interactive churn",
+		Long: `graph builds the weighted directed network of authors over a revision range: an edge
+from A to B weighs the number of lines written by B that A deleted. Every node carries
+its degree, weighted in and out strength and betweenness centrality.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			write, ok := map[string]func(*metrics.InteractionGraph, io.Writer) error{
+				"dot":     (*metrics.InteractionGraph).WriteDOT,
+				"graphml": (*metrics.InteractionGraph).WriteGraphML,
+				"json":    (*metrics.InteractionGraph).WriteJSON,
+			}[graphFormat]
+			if !ok {
+				CheckIfError(fmt.Errorf("unknown graph format %q, must be dot, graphml or json", graphFormat))
+			}
+			ctx, cancel := runContext()
+			defer cancel()
+			from, to, err := metrics.CommitRange(ctx, repoUrl, graphRange)
+			CheckIfError(err)
+			churns, err := metrics.Churns(ctx, to, &metrics.ChurnOptions{Path: filepath, From: from})
+			CheckIfError(err)
+			g := metrics.NewInteractionGraph(churns)
+
+			if graphOutput == "" {
+				CheckIfError(write(g, os.Stdout))
+				return
+			}
+			f, err := os.Create(graphOutput)
+			CheckIfError(err)
+			err = write(g, f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			CheckIfError(err)
+		},
+	}
+)
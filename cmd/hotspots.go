@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(hotspotsCmd)
+	f := hotspotsCmd.Flags()
+	f.StringVar(&hotspotsRev, "rev", "HEAD", "Revision whose files are ranked")
+	f.StringVar(&hotspotsSince, "since", "", "Leaves out the commits authored before this date, e.g. 2020-01-31")
+	f.StringVar(&hotspotsUntil, "until", "", "Leaves out the commits authored after this date")
+	f.StringToStringVar(&hotspotsWeights, "weights", nil,
+		"Weights of the factors of the score: frequency, interactive, authors, loc and complexity, e.g. frequency=2,loc=0.5, 1 by default")
+	f.StringVar(&hotspotsComplexity, "complexity", "", "CSV (path,value) or JSON ({\"path\": value}) file of the complexity of the files, measured by another tool")
+	f.IntVar(&hotspotsTop, "top", 20, "Number of files printed, 0 for all")
+	f.BoolVar(&hotspotsJSON, "json", false, "Prints the hotspots as JSON")
+}
+
+var (
+	hotspotsRev        string
+	hotspotsSince      string
+	hotspotsUntil      string
+	hotspotsWeights    map[string]string
+	hotspotsComplexity string
+	hotspotsTop        int
+	hotspotsJSON       bool
+
+	hotspotsCmd = &cobra.Command{
+		Use:   "hotspots",
+		Short: "Ranks the files by a hotspot score combining churn, authors and size",
+		Long: `hotspots ranks the files of the given revision by a score combining how often they are
+changed, the share of their churn that is interactive, the number of distinct authors
+changing them, their number of lines and, optionally, a complexity measured by another
+tool. Every factor is divided by its maximum over the files, and the score is their
+weighted mean. Only the commits in the time window are counted, and merges are left out.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			opts := metrics.HotspotOptions{Prefix: filepath, Weights: metrics.DefaultHotspotWeights}
+			var err error
+			opts.Since, err = parseDate(hotspotsSince)
+			CheckIfError(err)
+			opts.Until, err = parseUntil(hotspotsUntil)
+			CheckIfError(err)
+			CheckIfError(parseWeights(hotspotsWeights, &opts.Weights))
+			if hotspotsComplexity != "" {
+				opts.Complexity, err = readComplexity(hotspotsComplexity)
+				CheckIfError(err)
+			}
+
+			ctx, cancel := runContext()
+			defer cancel()
+			commitObj, err := metrics.CommitAt(ctx, repoUrl, hotspotsRev)
+			CheckIfError(err)
+			hotspots, err := metrics.Hotspots(ctx, commitObj, opts)
+			CheckIfError(err)
+			if hotspotsTop > 0 && len(hotspots) > hotspotsTop {
+				hotspots = hotspots[:hotspotsTop]
+			}
+
+			if hotspotsJSON {
+				data, err := json.MarshalIndent(hotspots, "", "  ")
+				CheckIfError(err)
+				fmt.Println(string(data))
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FILE\tLOC\tCOMMITS\tAUTHORS\tSELF CHURN\tINTERACTIVE CHURN\tINTERACTIVE\tCOMPLEXITY\tSCORE")
+			for _, h := range hotspots {
+				complexity := "-"
+				if opts.Complexity != nil {
+					complexity = strconv.FormatFloat(h.Complexity, 'f', -1, 64)
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t%.3f\n", h.File, h.Lines, h.Commits, h.Authors,
+					h.SelfChurn, h.InteractiveChurn, h.InteractiveShare*100, complexity, h.Score)
+			}
+			w.Flush()
+		},
+	}
+)
+
+// parseWeights sets the weights given by name.
+func parseWeights(values map[string]string, weights *metrics.HotspotWeights) error {
+	for name, value := range values {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 {
+			return fmt.Errorf("invalid weight of %s: %q", name, value)
+		}
+		switch name {
+		case "frequency":
+			weights.Frequency = weight
+		case "interactive":
+			weights.Interactive = weight
+		case "authors":
+			weights.Authors = weight
+		case "loc":
+			weights.Lines = weight
+		case "complexity":
+			weights.Complexity = weight
+		default:
+			return fmt.Errorf("unknown weight %q, must be frequency, interactive, authors, loc or complexity", name)
+		}
+	}
+	return nil
+}
+
+// readComplexity reads the complexity of the files from a JSON object or
+// from CSV records of a path and a value. A CSV header is skipped.
+func readComplexity(name string) (map[string]float64, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]float64)
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return result, nil
+	}
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s:%d: a path and a value are required", name, line)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid value %q", name, line, record[1])
+		}
+		result[strings.TrimSpace(record[0])] = value
+	}
+	return result, nil
+}
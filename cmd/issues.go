@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	var names []string
+	for _, e := range metrics.DefaultIssueExtractors {
+		names = append(names, e.Name)
+	}
+	pf := rootCmd.PersistentFlags()
+	pf.StringSliceVar(&issueExtractorNames, "issue-extractors", names,
+		"Extractors of the issues and pull requests of the commits: "+strings.Join(names, ", "))
+	pf.StringSliceVar(&issuePatterns, "issue-pattern", nil, "Regular expression matching issue ids in commit messages, the id is its first group if it has one, can be repeated")
+	pf.StringSliceVar(&prPatterns, "pr-pattern", nil, "Regular expression matching pull request ids in commit messages, can be repeated")
+
+	rootCmd.Flags().StringVarP(&aggregate, "aggregate", "a", "", "Prints the churn aggregated by: issue, the issues and pull requests of the commits")
+}
+
+var (
+	issueExtractorNames []string
+	issuePatterns       []string
+	prPatterns          []string
+	aggregate           string
+)
+
+// The ways the churn can be aggregated.
+const aggregateIssue = "issue"
+
+// issueExtractors returns the extractors selected by the flags.
+func issueExtractors() ([]metrics.IssueExtractor, error) {
+	var result []metrics.IssueExtractor
+	for _, name := range issueExtractorNames {
+		found := false
+		for _, e := range metrics.DefaultIssueExtractors {
+			if e.Name == name {
+				result = append(result, e)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown issue extractor %q", name)
+		}
+	}
+	for i, patterns := range [][]string{issuePatterns, prPatterns} {
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid issue pattern: %w", err)
+			}
+			result = append(result, metrics.IssueExtractor{Name: pattern, Pattern: re, PullRequest: i == 1})
+		}
+	}
+	return result, nil
+}
+
+// printIssueChurn prints the churn of the commits aggregated by issue and
+// pull request.
+func printIssueChurn(churns []metrics.Churn) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tCOMMITS\tSELF CHURN\tINTERACTIVE CHURN\tTOTAL")
+	for _, ic := range metrics.AggregateIssues(churns) {
+		kind := "issue"
+		if ic.PullRequest {
+			kind = "pr"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", ic.ID, kind, ic.Commits, ic.SelfChurn, ic.InteractiveChurn,
+			ic.SelfChurn+ic.InteractiveChurn)
+	}
+	w.Flush()
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(ownershipCmd)
+	f := ownershipCmd.Flags()
+	f.StringVar(&ownershipRev, "rev", "HEAD", "Revision at which the ownership has to be computed")
+	f.BoolVar(&ownershipJSON, "json", false, "Prints the ownership as JSON")
+}
+
+var (
+	ownershipRev  string
+	ownershipJSON bool
+
+	ownershipCmd = &cobra.Command{
+		Use:   "ownership",
+		Short: "Reports the share of surviving lines per author of every file and directory",
+		Long: `ownership reports, for every file and directory at the given revision, the share of
+surviving lines written by each author, the top owner and the minor contributors
+(authors owning less than 5% of the lines).`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			ctx, cancel := runContext()
+			defer cancel()
+			commitObj, err := metrics.CommitAt(ctx, repoUrl, ownershipRev)
+			CheckIfError(err)
+			result, err := metrics.ComputeOwnership(ctx, commitObj, filepath)
+			CheckIfError(err)
+
+			if ownershipJSON {
+				data, err := json.MarshalIndent(result, "", "  ")
+				CheckIfError(err)
+				fmt.Println(string(data))
+				return
+			}
+			printOwnerships("DIRECTORY", result.Directories)
+			fmt.Println()
+			printOwnerships("FILE", result.Files)
+		},
+	}
+)
+
+func printOwnerships(kind string, ownerships []metrics.Ownership) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tLINES\tAUTHORS\tTOP OWNER\tSHARE\tMINOR CONTRIBUTORS\n", kind)
+	for _, o := range ownerships {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%.1f%%\t%s\n", o.Path, o.TotalLines, len(o.Authors),
+			o.TopOwner, o.TopOwnerShare*100, strings.Join(o.MinorContributors, ","))
+	}
+	w.Flush()
+}
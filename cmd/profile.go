@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	pf := rootCmd.PersistentFlags()
+	pf.StringVar(&cpuProfile, "cpuprofile", "", "Writes a CPU profile of the run to `file`")
+	pf.StringVar(&memProfile, "memprofile", "", "Writes a heap profile at the end of the run to `file`")
+	pf.StringVar(&allocProfile, "allocprofile", "", "Writes a profile of all the allocations of the run to `file`")
+	pf.StringVar(&traceFile, "trace", "", "Writes an execution trace of the run to `file`")
+	pf.BoolVar(&printRunStats, "stats", false, "Reports the time and the allocations of every phase to the standard error at the end of the run")
+}
+
+var (
+	cpuProfile    string
+	memProfile    string
+	allocProfile  string
+	traceFile     string
+	printRunStats bool
+
+	// profiles are the files of the profiles being written, closed by
+	// stopProfiling.
+	profiles []*os.File
+	// runStats collects the stats of the run when --stats is set.
+	runStats *runStatsReport
+)
+
+type runStatsReport struct {
+	stats  metrics.Stats
+	start  time.Time
+	before runtime.MemStats
+}
+
+// startProfiling starts the CPU profile and the execution trace, if they are
+// asked for.
+func startProfiling() error {
+	if cpuProfile != "" {
+		f, err := createProfile(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("could not start CPU profile: %w", err)
+		}
+	}
+	if traceFile != "" {
+		f, err := createProfile(traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			return fmt.Errorf("could not start trace: %w", err)
+		}
+	}
+	return nil
+}
+
+func createProfile(name string) (*os.File, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	profiles = append(profiles, f)
+	return f, nil
+}
+
+// stopProfiling stops the CPU profile and the trace, and writes the heap and
+// allocation profiles. It does nothing when called again.
+func stopProfiling() {
+	if cpuProfile != "" {
+		pprof.StopCPUProfile()
+		cpuProfile = ""
+	}
+	if traceFile != "" {
+		trace.Stop()
+		traceFile = ""
+	}
+	if memProfile != "" {
+		runtime.GC()
+		writeProfile("heap", memProfile)
+		memProfile = ""
+	}
+	if allocProfile != "" {
+		writeProfile("allocs", allocProfile)
+		allocProfile = ""
+	}
+	for _, f := range profiles {
+		f.Close()
+	}
+	profiles = nil
+}
+
+func writeProfile(name, file string) {
+	f, err := createProfile(file)
+	if err == nil {
+		err = pprof.Lookup(name).WriteTo(f, 0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not write %s profile: %s\n", name, err)
+	}
+}
+
+// newRunStats returns the stats of a run starting now, nil unless --stats is
+// set.
+func newRunStats() *runStatsReport {
+	if !printRunStats {
+		return nil
+	}
+	r := &runStatsReport{start: time.Now()}
+	runtime.ReadMemStats(&r.before)
+	return r
+}
+
+// print writes the report of the stats to the standard error. It does nothing
+// if r is nil.
+func (r *runStatsReport) print() {
+	if r == nil {
+		return
+	}
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tCALLS\tTIME\tALLOCS\tALLOCATED")
+	for _, p := range r.stats.Phases() {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\n", p.Phase, p.Calls, p.Duration.Round(time.Microsecond),
+			p.Allocs, formatBytes(p.AllocBytes))
+	}
+	fmt.Fprintf(w, "total\t\t%s\t%d\t%s\n", time.Since(r.start).Round(time.Microsecond),
+		after.Mallocs-r.before.Mallocs, formatBytes(after.TotalAlloc-r.before.TotalAlloc))
+	w.Flush()
+
+	fmt.Fprintf(os.Stderr, "\ncommits: %d, files: %d, blobs read: %d, diff bytes: %s\n",
+		r.stats.Commits, r.stats.Files, r.stats.Blobs, formatBytes(uint64(r.stats.DiffBytes)))
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
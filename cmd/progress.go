@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	pf := rootCmd.PersistentFlags()
+	pf.BoolVarP(&quiet, "quiet", "q", false, "Does not report the progress of the run")
+	pf.StringVar(&progressFormat, "progress-format", "auto",
+		"Format of the progress reported to the standard error: text, json, or auto for text on a terminal and json otherwise")
+}
+
+var (
+	quiet          bool
+	progressFormat string
+	// reporter is the progress reporter of the run, if any.
+	reporter *progressReporter
+)
+
+// The minimum intervals between two progress reports, unless the phase
+// changes or ends.
+const (
+	textProgressInterval = 200 * time.Millisecond
+	jsonProgressInterval = time.Second
+)
+
+// progressReporter writes the progress events of a run to the standard error,
+// as a status line or as one JSON object per line.
+type progressReporter struct {
+	mu       sync.Mutex
+	out      io.Writer
+	json     bool
+	interval time.Duration
+	phase    string
+	last     time.Time
+	// open tells if a status line was written that was not terminated yet.
+	open bool
+}
+
+// newProgressReporter returns the reporter configured by the flags, nil in
+// quiet mode.
+func newProgressReporter() (*progressReporter, error) {
+	if quiet {
+		return nil, nil
+	}
+	r := &progressReporter{out: os.Stderr}
+	switch progressFormat {
+	case "auto":
+		r.json = !terminal.IsTerminal(int(os.Stderr.Fd()))
+	case "text":
+	case "json":
+		r.json = true
+	default:
+		return nil, fmt.Errorf("unknown progress format %q, must be auto, text or json", progressFormat)
+	}
+	r.interval = textProgressInterval
+	if r.json {
+		r.interval = jsonProgressInterval
+	}
+	return r, nil
+}
+
+func (r *progressReporter) report(ev metrics.ProgressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	finished := ev.Total > 0 && ev.Done == ev.Total
+	if ev.Phase == r.phase && !finished && now.Sub(r.last) < r.interval {
+		return
+	}
+	if r.json {
+		data, _ := json.Marshal(ev)
+		fmt.Fprintln(r.out, string(data))
+	} else {
+		if r.open && ev.Phase != r.phase {
+			fmt.Fprintln(r.out)
+		}
+		fmt.Fprint(r.out, "\r\x1b[K"+progressLine(ev))
+		r.open = true
+		if finished {
+			fmt.Fprintln(r.out)
+			r.open = false
+		}
+	}
+	r.phase = ev.Phase
+	r.last = now
+}
+
+// close terminates the status line, if any.
+func (r *progressReporter) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.open {
+		fmt.Fprintln(r.out)
+		r.open = false
+	}
+}
+
+func progressLine(ev metrics.ProgressEvent) string {
+	var b strings.Builder
+	b.WriteString(ev.Phase)
+	if ev.Message != "" {
+		b.WriteString(": " + ev.Message)
+		return b.String()
+	}
+	if ev.Total > 0 {
+		b.WriteString(fmt.Sprintf(": %d/%d commits (%.0f%%)", ev.Done, ev.Total, float64(ev.Done)*100/float64(ev.Total)))
+	} else if ev.Done > 0 {
+		b.WriteString(fmt.Sprintf(": %d commits", ev.Done))
+	}
+	if ev.Files > 0 {
+		b.WriteString(fmt.Sprintf(", %.0f files/s", ev.FilesPerSecond))
+	}
+	b.WriteString(", elapsed " + ev.Elapsed.Round(time.Second).String())
+	if ev.ETA > 0 {
+		b.WriteString(", ETA " + ev.ETA.Round(time.Second).String())
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+	f := reviewCmd.Flags()
+	f.StringVar(&reviewOptions.Author, "author", "", "Email of the author of the change, the author of the head by default")
+	f.IntVar(&reviewOptions.MaxReviewers, "reviewers", 5, "Number of reviewers suggested, 0 for all")
+	f.StringVar(&reviewFormat, "format", "text", "Format of the report: text, json or markdown")
+}
+
+var (
+	reviewOptions metrics.ReviewOptions
+	reviewFormat  string
+
+	reviewCmd = &cobra.Command{
+		Use:     "review BASE [HEAD]",
+		Aliases: []string{"risk"},
+		Short:   "Reports whose code a branch rewrites before it is merged into a base",
+		Long: `review computes the merge base of BASE and HEAD (HEAD by default) and attributes the
+changes of HEAD as a single commit on top of it. For every file it reports the authors
+whose lines are deleted or changed, the age of these lines and the self and interactive
+churn, and it suggests the other authors of these lines as reviewers.
+
+The branches of the repository are cloned as remote branches, e.g. origin/main.`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			head := "HEAD"
+			if len(args) == 2 {
+				head = args[1]
+			}
+			if reviewFormat != "text" && reviewFormat != "json" && reviewFormat != "markdown" {
+				CheckIfError(fmt.Errorf("unknown format %q, must be text, json or markdown", reviewFormat))
+			}
+			reviewOptions.Prefix = filepath
+
+			ctx, cancel := runContext()
+			defer cancel()
+			r, err := metrics.OpenRepo(ctx, repoUrl)
+			CheckIfError(err)
+			report, err := metrics.Review(ctx, r, args[0], head, reviewOptions)
+			CheckIfError(err)
+
+			switch reviewFormat {
+			case "json":
+				data, err := json.MarshalIndent(report, "", "  ")
+				CheckIfError(err)
+				fmt.Println(string(data))
+			case "markdown":
+				printReviewMarkdown(os.Stdout, report)
+			default:
+				printReviewText(os.Stdout, report)
+			}
+		},
+	}
+)
+
+// printReviewText prints the report as tables.
+func printReviewText(out io.Writer, report *metrics.ReviewReport) {
+	fmt.Fprintf(out, "merge base %s, head %s by %s\n", report.MergeBase[:8], report.Head[:8], report.Author)
+	fmt.Fprintf(out, "%d lines deleted: %d self churn, %d interactive churn, aged %d to %d days, median %d\n\n",
+		report.Deleted, report.SelfChurn, report.InteractiveChurn, report.Age.Newest, report.Age.Oldest,
+		report.Age.Median)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tDELETED\tSELF CHURN\tINTERACTIVE CHURN\tAGE (DAYS)\tOWNERS")
+	for _, f := range report.Files {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", f.File, f.Deleted, f.SelfChurn, f.InteractiveChurn, ageRange(f.Age),
+			owners(f.Owners))
+	}
+	w.Flush()
+	if len(report.Reviewers) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REVIEWER\tLINES\tFILES\tLAST CHANGE")
+	for _, r := range report.Reviewers {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", r.Author, r.Lines, r.Files, r.LastChange.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+// printReviewMarkdown prints the report as Markdown, e.g. for a pull request
+// comment.
+func printReviewMarkdown(out io.Writer, report *metrics.ReviewReport) {
+	fmt.Fprintf(out, "### Churn of %s on %s\n\n", report.Head[:8], report.MergeBase[:8])
+	fmt.Fprintf(out, "%d lines deleted: %d self churn, %d interactive churn, aged %d to %d days, median %d.\n\n",
+		report.Deleted, report.SelfChurn, report.InteractiveChurn, report.Age.Newest, report.Age.Oldest,
+		report.Age.Median)
+	if len(report.Reviewers) != 0 {
+		fmt.Fprintln(out, "Suggested reviewers:")
+		fmt.Fprintln(out)
+		for _, r := range report.Reviewers {
+			fmt.Fprintf(out, "- %s: %d lines in %d files, last changed %s\n", r.Author, r.Lines, r.Files,
+				r.LastChange.Format("2006-01-02"))
+		}
+		fmt.Fprintln(out)
+	}
+	if len(report.Files) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "| File | Deleted | Self churn | Interactive churn | Age (days) | Owners |")
+	fmt.Fprintln(out, "|---|--:|--:|--:|--:|---|")
+	for _, f := range report.Files {
+		fmt.Fprintf(out, "| `%s` | %d | %d | %d | %s | %s |\n", f.File, f.Deleted, f.SelfChurn, f.InteractiveChurn,
+			ageRange(f.Age), owners(f.Owners))
+	}
+}
+
+// ageRange formats the ages of lines as newest-oldest.
+func ageRange(age metrics.LineAge) string {
+	if age.Newest == age.Oldest {
+		return fmt.Sprint(age.Newest)
+	}
+	return fmt.Sprintf("%d-%d", age.Newest, age.Oldest)
+}
+
+// owners formats the owners of lines and their number of lines.
+func owners(owners []metrics.LineOwner) string {
+	s := make([]string, len(owners))
+	for i, o := range owners {
+		s[i] = fmt.Sprintf("%s (%d)", o.Author, o.Lines)
+	}
+	return strings.Join(s, ", ")
+}
@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/ashishgalagali/go-git-churn/helper"
+	"github.com/ashishgalagali/go-git-churn/linediff"
 	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/spf13/cobra"
-	//"io/ioutil"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -18,36 +26,80 @@ func init() {
 	pf.StringVarP(&lastCommitId, "commit", "c", "", "The Last commit hash till which the metrics has to be computed")
 	////print.CheckIfError(cobra.MarkFlagRequired(pf, "commit"))
 	pf.StringVarP(&filepath, "filepath", "f", "", "File path to filter file on which the churn metrics has to be computed")
-	//pf.StringVarP(&aggregate, "aggregate", "a", "", "Aggregate the churn metrics. \"commit\": Aggregates all files in a commit. \"all\": Aggregate all files all commits and all files")
 	//pf.BoolVarP(&whitespace git-churn, "whitespace", "w", true, "Excludes whitespaces while calculating the churn metrics is set to false")
 	//pf.BoolVarP(&jsonOPToFile, "json", "j", false, "Writes the JSON output to a file within a folder named churn-details")
 	//pf.BoolVarP(&printOP, "print", "p", true, "Prints the output in a human readable format")
-	//pf.BoolVarP(&enableLog, "logging", "l", false, "Enables logging. Defaults to false")
+	pf.StringVarP(&logLevel, "log-level", "l", helper.DefaultLogConfig.Level.String(), "Least severe level logged: debug, info, warn or error")
+	pf.StringVar(&logFile, "log-file", "", "File the logs are appended to, defaults to the standard error")
+	pf.StringVar(&logFormat, "log-format", "text", "Format of the logs: text or json")
+
+	pf.StringVar(&diffAlgorithm, "diff-algorithm", string(linediff.Myers), "Line diff algorithm: myers, patience or histogram")
+	pf.IntVar(&metrics.Moves.MinLines, "detect-moves", 0, "Detects the blocks of at least this many lines moved within a file, which keep their origin, 0 to disable")
+	pf.CountVarP(&metrics.Moves.Copies, "detect-copies", "C", "Also detects the lines moved across the files of a commit, and if given twice the lines of new files copied from any file")
+	pf.BoolVar(&metrics.Moves.IgnoreWhitespace, "moves-ignore-whitespace", false, "Ignores whitespace when detecting moved lines")
+	pf.StringSliceVar(&metrics.Ignore.Revs, "ignore-rev", nil, "Hash of a commit of the history whose changes are attributed to the lines they match and that has no churn, can be repeated")
+	pf.StringSliceVar(&ignoreRevsFiles, "ignore-revs-file", nil, "File listing the hashes of ignored commits, one per line, the ones not in the history are skipped, can be repeated")
+	pf.BoolVar(&metrics.Ignore.NoDefaultFile, "no-default-ignore-revs", false, "Does not ignore the commits listed in the "+metrics.IgnoreRevsFile+" file of the repository")
+	pf.DurationVar(&timeout, "timeout", 0, "Stops the run after this duration, e.g. 30m, writing the partial result")
+	pf.StringVar(&metrics.CacheDir, "cache-dir", "", "Directory remote repositories are cached in, they are then only fetched by the following runs")
+
+	pf.StringVar(&metrics.Auth.Username, "username", "", "User name for HTTP(S) remotes, or $"+metrics.EnvUsername)
+	pf.StringVar(&metrics.Auth.Password, "password", "", "Password for HTTP(S) remotes, or $"+metrics.EnvPassword)
+	pf.StringVar(&metrics.Auth.Token, "token", "", "Access token for HTTP(S) remotes, or $"+metrics.EnvToken)
+	pf.BoolVar(&metrics.Auth.CredentialHelper, "credential-helper", false, "Asks the git credential helpers for the credentials of HTTP(S) remotes")
+	pf.StringVar(&metrics.Auth.SSHKeyFile, "ssh-key", "", "Private key file for SSH remotes, or $"+metrics.EnvSSHKey)
+	pf.StringVar(&metrics.Auth.SSHKeyPassphrase, "ssh-passphrase", "", "Passphrase of the SSH private key, or $"+metrics.EnvSSHPassphrase)
+	pf.BoolVar(&metrics.Auth.SSHAgent, "ssh-agent", false, "Uses the ssh-agent for SSH remotes, the default when no key is given, exclusive with --ssh-key")
+	pf.StringVar(&metrics.Auth.KnownHosts, "known-hosts", metrics.KnownHostsStrict, "Host key policy for SSH remotes: strict, accept-new or insecure")
+	pf.StringSliceVar(&metrics.Auth.KnownHostsFiles, "known-hosts-file", nil, "known_hosts files, defaults to the ones in $SSH_KNOWN_HOSTS or else to the ones of the user and the system")
+
+	f := rootCmd.Flags()
+	f.StringSliceVar(&tipOptions.Refs, "ref", nil, "Branch, tag, remote branch or revision whose history is walked instead of HEAD, can be repeated")
+	f.BoolVar(&tipOptions.Branches, "branches", false, "Walks the history of all the branches")
+	f.BoolVar(&tipOptions.Tags, "tags", false, "Walks the history of all the tags")
+	f.BoolVar(&tipOptions.All, "all", false, "Walks the history of all the refs")
 }
 
 var (
-	repoUrl      string
-	lastCommitId string
-	filepath     string
+	repoUrl         string
+	lastCommitId    string
+	filepath        string
+	timeout         time.Duration
+	logLevel        string
+	logFile         string
+	logFormat       string
+	tipOptions      metrics.TipOptions
+	diffAlgorithm   string
+	ignoreRevsFiles []string
 	//whitespace   bool
 	//jsonOPToFile bool
 	//printOP      bool
-	//aggregate    string
-	//enableLog    bool
 
 	rootCmd = &cobra.Command{
 		Use:   "go-git-churn",
 		Short: "A fast tool for collecting code churn metrics from git repositories.",
 		Long: `go-git-churn gives the churn metrics like self-churn, interactive-churn for the given repo.
                Complete documentation is available at https://github.com/ashishgalagali/go-git-churn`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			level, err := helper.ParseLevel(logLevel)
+			CheckIfError(err)
+			CheckIfError(helper.ConfigureLogging(helper.LogConfig{Level: level, File: logFile, Format: logFormat}))
+			metrics.Auth.LoadEnv()
+			metrics.DiffAlgorithm, err = linediff.ParseAlgorithm(diffAlgorithm)
+			CheckIfError(err)
+			metrics.IssueExtractors, err = issueExtractors()
+			CheckIfError(err)
+			for _, name := range ignoreRevsFiles {
+				CheckIfError(readIgnoreRevs(name))
+			}
+			CheckIfError(startProfiling())
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			stopProfiling()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			//if !enableLog {
-			//	helper.INFO.SetFlags(0)
-			//	helper.INFO.SetOutput(ioutil.Discard)
-			//}
-			//helper.INFO.Println("\n Processing new request")
-			//helper.INFO.Println("")
 			//var churnMetrics interface{}
+			var result *metrics.BlameResult
 			var err error
 
 			//commitIds := strings.Split(commitId, "..")
@@ -68,19 +120,50 @@ var (
 			if repoUrl == "" {
 				repoUrl = "."
 			}
+			if aggregate != "" && aggregate != aggregateIssue {
+				CheckIfError(fmt.Errorf("unknown aggregation %q, must be %s", aggregate, aggregateIssue))
+			}
+			metrics.KeepChurns = aggregate != ""
 			//repo := metrics.GetRepo(repoUrl)
 			//print.PrintInBlue(repoUrl + " " + commitId + " " + filepath + " " + firstCommitId)
-			//helper.INFO.Println("Generating git-churn for the following: \n" + "Repo:" + repoUrl + " " + " commitId:" + commitId + " " + " filepath:" + filepath + " " + " firstCommitId:" + firstCommitId)
+			helper.Info("generating git-churn", "repo", repoUrl, "commit", lastCommitId, "filepath", filepath)
 
 			//r := metrics.Checkout("https://github.com/ashishgalagali/SWEN610-project", "7368d5fcb7eec950161ed9d13b55caf5961326b6")
 			//
 			//h, err := r.ResolveRevision(plumbing.Revision("7368d5fcb7eec950161ed9d13b55caf5961326b6"))
 			//CheckIfError(err)
-			commitObj := metrics.LastCommit(repoUrl)
-			CheckIfError(err)
-			_, err = metrics.Blame(commitObj, filepath, lastCommitId)
+			ctx, cancel := runContext()
+			defer cancel()
+			if pendingWorktree || pendingStaged {
+				CheckIfError(printPendingChurn(ctx))
+				return
+			}
+			if len(tipOptions.Refs) != 0 || tipOptions.Branches || tipOptions.Tags || tipOptions.All {
+				var r *git.Repository
+				r, err = metrics.OpenRepo(ctx, repoUrl)
+				CheckIfError(err)
+				var tips []metrics.Tip
+				tips, err = metrics.ResolveTips(r, tipOptions)
+				CheckIfError(err)
+				if len(tips) == 0 {
+					CheckIfError(errors.New("no ref to walk"))
+				}
+				result, err = metrics.BlameTips(ctx, tips, filepath, lastCommitId)
+			} else {
+				var commitObj *object.Commit
+				commitObj, err = metrics.LastCommit(ctx, repoUrl)
+				CheckIfError(err)
+				result, err = metrics.Blame(ctx, commitObj, filepath, lastCommitId)
+			}
 
+			var interrupted *metrics.InterruptedError
+			if errors.As(err, &interrupted) && interrupted.OutputFile != "" {
+				fmt.Fprintln(os.Stderr, "The partial result was written to "+interrupted.OutputFile)
+			}
 			CheckIfError(err)
+			if aggregate == aggregateIssue {
+				printIssueChurn(result.Churns)
+			}
 
 			//fmt.Println(fmt.Sprintf("%v", churnMetrics))
 
@@ -104,12 +187,93 @@ func Execute() {
 	}
 }
 
-// CheckIfError should be used to naively panics if an error is not nil.
+// runContext returns the context of a run: it is done when the --timeout
+// expires or when the process receives SIGINT or SIGTERM, it reports the
+// progress of the run unless --quiet is set and collects its stats if --stats
+// is set. The stats are printed when the run is cancelled.
+func runContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	r, err := newProgressReporter()
+	CheckIfError(err)
+	if r != nil {
+		reporter = r
+		ctx = metrics.WithProgress(ctx, r.report)
+	}
+	if runStats = newRunStats(); runStats != nil {
+		ctx = metrics.WithStats(ctx, &runStats.stats)
+	}
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {
+		cancel()
+		stop()
+		endRun()
+	}
+}
+
+// endRun terminates the progress line and prints the stats of the run.
+func endRun() {
+	reporter.close()
+	runStats.print()
+	runStats = nil
+}
+
+// readIgnoreRevs adds the commits listed in the file name to the ignored
+// ones.
+func readIgnoreRevs(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	revs, err := metrics.ReadIgnoreRevs(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	metrics.Ignore.ListedRevs = append(metrics.Ignore.ListedRevs, revs...)
+	return nil
+}
+
+// The exit codes of the process for every kind of error.
+const (
+	ExitError              = 1
+	ExitRepositoryNotFound = 3
+	ExitRevisionNotFound   = 4
+	ExitObjectMissing      = 5
+	ExitPathNotInHistory   = 6
+	ExitCancelled          = 7
+)
+
+// exitCode returns the exit code for the kind of err.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, metrics.ErrRepositoryNotFound):
+		return ExitRepositoryNotFound
+	case errors.Is(err, metrics.ErrRevisionNotFound):
+		return ExitRevisionNotFound
+	case errors.Is(err, metrics.ErrObjectMissing):
+		return ExitObjectMissing
+	case errors.Is(err, metrics.ErrPathNotInHistory):
+		return ExitPathNotInHistory
+	case errors.Is(err, metrics.ErrCancelled), errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return ExitCancelled
+	default:
+		return ExitError
+	}
+}
+
+// CheckIfError prints the error, if it is not nil, and exits with the exit
+// code of its kind.
 func CheckIfError(err error) {
 	if err == nil {
 		return
 	}
 
-	fmt.Printf("\x1b[31;1m%s\x1b[0m\n", fmt.Sprintf("error: %s", err))
-	os.Exit(1)
+	endRun()
+	stopProfiling()
+	fmt.Fprintf(os.Stderr, "\x1b[31;1m%s\x1b[0m\n", fmt.Sprintf("error: %s", err))
+	os.Exit(exitCode(err))
 }
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/server"
+	"github.com/spf13/cobra"
+	"net/http"
+	"time"
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	f := serveCmd.Flags()
+	f.StringVar(&serveAddr, "addr", ":8080", "Address the HTTP API listens on")
+	f.IntVar(&serveWorkers, "workers", 1, "Number of jobs run concurrently")
+	f.IntVar(&serveQueue, "queue", 16, "Number of jobs that can wait for a worker")
+	f.DurationVar(&serveTTL, "job-ttl", time.Hour, "How long the jobs that ended are kept with their results")
+	f.IntVar(&serveMaxJobs, "max-jobs", 100, "Number of jobs that ended kept with their results, the oldest first forgotten")
+	f.IntVar(&serveMaxRepos, "max-repos", 16, "Number of repositories kept open for the next jobs, the least recently used first closed")
+}
+
+var (
+	serveAddr     string
+	serveWorkers  int
+	serveQueue    int
+	serveTTL      time.Duration
+	serveMaxJobs  int
+	serveMaxRepos int
+
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serves the churn metrics over a JSON HTTP API",
+		Long: `serve starts an HTTP server to submit churn jobs, poll their status and progress,
+fetch their paged results and cancel them:
+
+  POST   /jobs                {"Repo": "...", "Range": "from..to", "Paths": ["dir"], "Path": "file",
+                               "Since": "2020-01-31T00:00:00Z", "Until": "2020-12-31T00:00:00Z",
+                               "Moves": {"MinLines": 3, "IgnoreWhitespace": true}}
+  GET    /jobs
+  GET    /jobs/{id}
+  GET    /jobs/{id}/results?offset=0&limit=100
+  DELETE /jobs/{id}
+
+At most --max-repos repositories are kept open, and they are fetched again for every
+job. The jobs that ended are kept for --job-ttl, and at most --max-jobs of them. On
+SIGINT or SIGTERM the requests in progress are answered and the jobs are cancelled.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			CheckIfError(serve())
+		},
+	}
+)
+
+// serveShutdownTimeout is how long the requests in progress are waited for
+// when the server stops.
+const serveShutdownTimeout = 10 * time.Second
+
+// serve serves the HTTP API until the process receives SIGINT or SIGTERM,
+// then cancels the jobs.
+func serve() error {
+	s := server.New(server.Config{
+		Workers:   serveWorkers,
+		QueueSize: serveQueue,
+		TTL:       serveTTL,
+		MaxJobs:   serveMaxJobs,
+		MaxRepos:  serveMaxRepos,
+	})
+	defer s.Close()
+	ctx, cancel := runContext()
+	defer cancel()
+
+	hs := &http.Server{Addr: serveAddr, Handler: s}
+	errs := make(chan error, 1)
+	go func() { errs <- hs.ListenAndServe() }()
+	fmt.Println("Listening on " + serveAddr)
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancelShutdown()
+	return hs.Shutdown(ctx)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(survivalCmd)
+	f := survivalCmd.Flags()
+	f.StringVar(&survivalRev, "rev", "HEAD", "Revision at which the lines still alive are censored")
+	f.StringSliceVarP(&survivalGroups, "group-by", "g", metrics.SurvivalGroups,
+		"Groups to compute survival curves for: repository, directory, file, author, cohort")
+	f.BoolVar(&survivalJSON, "json", false, "Prints the survival curves, with all their points, as JSON")
+}
+
+var (
+	survivalRev    string
+	survivalGroups []string
+	survivalJSON   bool
+
+	survivalCmd = &cobra.Command{
+		Use:   "survival",
+		Short: "Computes the survival curves and the half-life of lines",
+		Long: `survival records the lifetime of every line written in the history of the given
+revision and reports Kaplan-Meier survival curves and the median half-life, in days,
+per repository, directory, file, author and cohort (the month the line was written).
+Lines alive at the revision are censored.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			ctx, cancel := runContext()
+			defer cancel()
+			commitObj, err := metrics.CommitAt(ctx, repoUrl, survivalRev)
+			CheckIfError(err)
+			lifetimes, err := metrics.Lifetimes(ctx, commitObj, filepath)
+			CheckIfError(err)
+
+			curves := make(map[string][]metrics.SurvivalCurve)
+			for _, group := range survivalGroups {
+				curves[group] = metrics.SurvivalCurves(lifetimes, group)
+			}
+
+			if survivalJSON {
+				data, err := json.MarshalIndent(curves, "", "  ")
+				CheckIfError(err)
+				fmt.Println(string(data))
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "GROUP\tKEY\tLINES\tDELETED\tCENSORED\tHALF-LIFE (DAYS)")
+			for _, group := range survivalGroups {
+				for _, c := range curves[group] {
+					halfLife := "-"
+					if c.MedianDays != nil {
+						halfLife = strconv.Itoa(*c.MedianDays)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", c.Group, c.Key, c.Lines, c.Deleted, c.Censored, halfLife)
+				}
+			}
+			w.Flush()
+		},
+	}
+)
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/synth"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+)
+
+func init() {
+	rootCmd.AddCommand(synthCmd)
+	f := synthCmd.Flags()
+	f.Int64Var(&synthSpec.Seed, "seed", synth.DefaultSpec.Seed, "Seed of the random choices, the same seed gives the same repository")
+	f.IntVar(&synthSpec.Authors, "authors", synth.DefaultSpec.Authors, "Number of authors")
+	f.IntVar(&synthSpec.Commits, "commits", synth.DefaultSpec.Commits, "Number of commits, merges included")
+	f.IntVar(&synthSpec.Files, "files", synth.DefaultSpec.Files, "Number of files added over the history")
+	f.IntVar(&synthSpec.Lines, "lines", synth.DefaultSpec.Lines, "Number of lines of the files when they are added")
+	f.Float64Var(&synthSpec.MergeRatio, "merge-ratio", synth.DefaultSpec.MergeRatio, "Probability that a commit starts a topic branch merged back later")
+	f.Float64Var(&synthSpec.RenameRatio, "rename-ratio", synth.DefaultSpec.RenameRatio, "Probability that a commit renames a file")
+	f.Float64Var(&synthSpec.RewriteRatio, "rewrite-ratio", synth.DefaultSpec.RewriteRatio, "Share of the lines of a file replaced by a commit modifying it")
+	f.StringVar(&synthExpected, "expected", "", "Writes the expected ownership of the lines and churn of the commits as JSON to this file")
+}
+
+var (
+	synthSpec     synth.Spec
+	synthExpected string
+
+	synthCmd = &cobra.Command{
+		Use:   "synth <directory>",
+		Short: "Builds a deterministic synthetic repository",
+		Long: `synth builds a git repository in the given directory from a seed and a shape: the
+number of authors, commits and files, and how often commits merge topic branches,
+rename files and how much of a file they rewrite. The same flags always build the same
+repository, to benchmark the tool at scale and to compare its results with the
+expected ownership and churn written by --expected.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			result, err := synth.BuildDir(args[0], synthSpec)
+			CheckIfError(err)
+			if synthExpected != "" {
+				data, err := json.MarshalIndent(result, "", "  ")
+				CheckIfError(err)
+				CheckIfError(ioutil.WriteFile(synthExpected, data, 0644))
+			}
+			fmt.Printf("Built %d commits and %d files in %s, head %s\n", synthSpec.Commits, len(result.Files),
+				args[0], result.Head)
+		},
+	}
+)
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/spf13/cobra"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	rootCmd.AddCommand(szzCmd)
+	f := szzCmd.Flags()
+	f.StringVar(&szzRev, "rev", "HEAD", "Revision whose history the fix commits are searched in")
+	f.StringSliceVar(&szzOptions.Fixes, "fix", nil, "Hash of a fix commit, can be repeated")
+	f.StringVar(&szzFixesFile, "fixes-file", "", "File listing the hashes of fix commits, one per line")
+	f.StringVar(&szzPattern, "fix-pattern", "", "Regular expression matching the messages of fix commits, e.g. '(?i)\\bfix(es|ed)?\\b'")
+	f.StringSliceVar(&szzIssues, "issue", nil, "Id of a fixed issue, mentioned by the messages of its fix commits, optionally followed by =DATE, the date it was reported, can be repeated")
+	f.StringVar(&szzIssuesFile, "issues-file", "", "File listing fixed issues, one per line: the id optionally followed by the date it was reported")
+	f.IntVar(&szzOptions.MaxFiles, "max-files", 100, "Number of files above which a commit is a mass change that is looked through, 0 for no limit")
+	f.BoolVar(&szzJSON, "json", false, "Prints the fixes and their candidates as JSON")
+}
+
+var (
+	szzRev        string
+	szzOptions    metrics.SZZOptions
+	szzFixesFile  string
+	szzPattern    string
+	szzIssues     []string
+	szzIssuesFile string
+	szzJSON       bool
+
+	szzCmd = &cobra.Command{
+		Use:   "szz",
+		Short: "Finds the commits that introduced the bugs fixed by fix commits",
+		Long: `szz implements the SZZ algorithm: the lines deleted or changed by every fix commit
+are traced back to the commits that introduced them, the candidate bug-introducing
+commits. The fix commits are listed, matched by a regular expression on their message
+or mention the ids of fixed issues.
+
+Blank lines, comments and the lines whose whitespace only changed are left out, and the
+mass changes (the commits changing only whitespace or more than --max-files files) as
+well as the ignored commits are looked through. The fix commits are traced back even if
+they are mass changes. The candidates authored after an issue was reported are dropped.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if repoUrl == "" {
+				repoUrl = "."
+			}
+			if szzFixesFile != "" {
+				fixes, err := readLines(szzFixesFile)
+				CheckIfError(err)
+				for _, line := range fixes {
+					szzOptions.Fixes = append(szzOptions.Fixes, strings.Fields(line)[0])
+				}
+			}
+			if szzPattern != "" {
+				var err error
+				szzOptions.Pattern, err = regexp.Compile(szzPattern)
+				CheckIfError(err)
+			}
+			if szzIssuesFile != "" {
+				lines, err := readLines(szzIssuesFile)
+				CheckIfError(err)
+				szzIssues = append(szzIssues, lines...)
+			}
+			for _, spec := range szzIssues {
+				issue, err := parseIssue(spec)
+				CheckIfError(err)
+				szzOptions.Issues = append(szzOptions.Issues, issue)
+			}
+			if len(szzOptions.Fixes) == 0 && szzOptions.Pattern == nil && len(szzOptions.Issues) == 0 {
+				CheckIfError(fmt.Errorf("no fix commits: --fix, --fixes-file, --fix-pattern, --issue or --issues-file is required"))
+			}
+			szzOptions.Prefix = filepath
+
+			ctx, cancel := runContext()
+			defer cancel()
+			commitObj, err := metrics.CommitAt(ctx, repoUrl, szzRev)
+			CheckIfError(err)
+			fixes, err := metrics.SZZ(ctx, commitObj, szzOptions)
+			CheckIfError(err)
+
+			if szzJSON {
+				data, err := json.MarshalIndent(fixes, "", "  ")
+				CheckIfError(err)
+				fmt.Println(string(data))
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "FIX\tISSUES\tCANDIDATE\tAUTHOR\tDATE\tLINES\tFILES")
+			for _, fix := range fixes {
+				issues := strings.Join(fix.Issues, ",")
+				if issues == "" {
+					issues = "-"
+				}
+				if len(fix.Candidates) == 0 {
+					fmt.Fprintf(w, "%s\t%s\t-\t\t\t\t\n", fix.CommitID[:8], issues)
+				}
+				for _, c := range fix.Candidates {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", fix.CommitID[:8], issues, c.CommitID[:8],
+						c.Author, c.Date.Format("2006-01-02"), c.Lines, strings.Join(c.Files, ","))
+				}
+			}
+			w.Flush()
+		},
+	}
+)
+
+// readLines returns the lines of the file name that are not blank, without
+// their comments.
+func readLines(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(stripComment(scanner.Text())); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// stripComment returns the line without its comment: the text from a # at the
+// start of the line or after a blank, unless a digit follows it like in the
+// GitHub issue ids, e.g. #123.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] != '#' || i > 0 && line[i-1] != ' ' && line[i-1] != '\t' {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			continue
+		}
+		return line[:i]
+	}
+	return line
+}
+
+// parseIssue parses an issue id, optionally followed by = or blanks and the
+// date it was reported, as 2006-01-02 or RFC 3339.
+func parseIssue(spec string) (metrics.Issue, error) {
+	fields := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == '=' || r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) == 0 || len(fields) > 2 {
+		return metrics.Issue{}, fmt.Errorf("invalid issue %q, must be ID or ID=DATE", spec)
+	}
+	issue := metrics.Issue{ID: fields[0]}
+	if len(fields) == 2 {
+		var err error
+		if issue.Reported, err = parseDate(fields[1]); err != nil {
+			return issue, fmt.Errorf("invalid date of issue %s: %w", fields[0], err)
+		}
+	}
+	return issue, nil
+}
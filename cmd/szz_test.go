@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "szz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := fp.Join(dir, "issues.txt")
+	contents := "# fixed issues\n\n#123 2020-01-01\nPROJ-7 # reported late\n  #45\t# no date\nGH-8#9\n#todo\n"
+	if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLines(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"#123 2020-01-01", "PROJ-7", "#45", "GH-8#9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines %q, want %q", got, want)
+	}
+}
+
+func TestParseIssue(t *testing.T) {
+	tests := []struct {
+		spec string
+		want metrics.Issue
+		ok   bool
+	}{
+		{"#123", metrics.Issue{ID: "#123"}, true},
+		{"#123 2020-01-02", metrics.Issue{ID: "#123", Reported: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}, true},
+		{"PROJ-7=2020-01-02T10:00:00Z", metrics.Issue{ID: "PROJ-7", Reported: time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)}, true},
+		{"PROJ-7,\t2020-01-02", metrics.Issue{ID: "PROJ-7", Reported: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}, true},
+		{"", metrics.Issue{}, false},
+		{"PROJ-7 2020-01-02 extra", metrics.Issue{}, false},
+		{"PROJ-7 yesterday", metrics.Issue{}, false},
+	}
+	for _, test := range tests {
+		got, err := parseIssue(test.spec)
+		if test.ok && (err != nil || got.ID != test.want.ID || !got.Reported.Equal(test.want.Reported)) {
+			t.Errorf("parseIssue(%q) = %+v, %v, want %+v", test.spec, got, err, test.want)
+		} else if !test.ok && err == nil {
+			t.Errorf("parseIssue(%q) = %+v, want an error", test.spec, got)
+		}
+	}
+}
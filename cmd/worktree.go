@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+func init() {
+	f := rootCmd.Flags()
+	f.BoolVar(&pendingWorktree, "worktree", false, "Prints the churn of the changes of the working tree of the local repository not committed yet, as a commit on top of HEAD, leaving out the untracked files unless added with git add -N")
+	f.BoolVar(&pendingStaged, "staged", false, "Prints the churn of the staged changes of the local repository, as a commit on top of HEAD")
+}
+
+var (
+	pendingWorktree bool
+	pendingStaged   bool
+)
+
+// printPendingChurn prints the churn of the changes of the working tree, or
+// of the staged ones, as JSON.
+func printPendingChurn(ctx context.Context) error {
+	if pendingWorktree && pendingStaged {
+		return errors.New("--worktree and --staged are exclusive")
+	}
+	if lastCommitId != "" || len(tipOptions.Refs) != 0 || tipOptions.Branches || tipOptions.Tags || tipOptions.All {
+		return errors.New("--worktree and --staged apply to HEAD, not to --commit, --ref, --branches, --tags or --all")
+	}
+	pending, err := metrics.PendingCommit(ctx, repoUrl, pendingStaged)
+	if err != nil {
+		return err
+	}
+	// the whole history is walked as HEAD may not change the file
+	opts := &metrics.ChurnOptions{}
+	if filepath != "" {
+		opts.Paths = []string{filepath}
+	}
+	churns, err := metrics.Churns(ctx, pending, opts)
+	if err != nil {
+		return err
+	}
+	for _, churn := range churns {
+		if churn.CommitID != pending.Hash.String() {
+			continue
+		}
+		data, err := json.MarshalIndent(churn, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+	return nil
+}
@@ -1,11 +1,11 @@
 module github.com/ashishgalagali/go-git-churn
 
-go 1.14
+go 1.16
 
 require (
 	github.com/go-git/go-billy/v5 v5.0.0
-	github.com/go-git/go-git v4.7.0+incompatible
 	github.com/go-git/go-git/v5 v5.1.0
-	github.com/sergi/go-diff v1.1.0
 	github.com/spf13/cobra v0.0.7
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
+	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527
 )
@@ -1,37 +1,204 @@
 package helper
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
-// INFO exported
-var INFO *log.Logger
+// Level is the severity of a log message.
+type Level int
 
-// ERROR exported
-var ERROR *log.Logger
+// The log levels, from the most to the least verbose.
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
 
-func init() {
-	fmt.Println("Checking/creating logs folder")
-	if _, err := os.Stat("../logs"); os.IsNotExist(err) {
-		fmt.Println("Creating logs folder")
+var levelNames = []string{"debug", "info", "warn", "error"}
 
-		os.MkdirAll("../logs", 0777)
+func (l Level) String() string {
+	if l < DebugLevel || l > ErrorLevel {
+		return fmt.Sprintf("level(%d)", int(l))
 	}
-	absPath, err := filepath.Abs("../logs")
-	fmt.Println("Created logs folder at : ", absPath)
-	if err != nil {
-		fmt.Println("Error reading given path:", err)
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name.
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, must be one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// LogConfig is the configuration of the logger.
+type LogConfig struct {
+	// Level is the least severe level logged.
+	Level Level
+	// File is the file the messages are appended to, the standard error if
+	// empty.
+	File string
+	// Format is either "text" or "json".
+	Format string
+}
+
+// DefaultLogConfig logs the warnings and errors as text to the standard error.
+var DefaultLogConfig = LogConfig{Level: WarnLevel, Format: "text"}
+
+type logger struct {
+	mu     sync.Mutex
+	level  Level
+	json   bool
+	out    io.Writer
+	closer io.Closer
+}
+
+var (
+	std   *logger
+	stdMu sync.Mutex
+)
+
+// getLogger returns the logger, configured with DefaultLogConfig if
+// ConfigureLogging was not called.
+func getLogger() *logger {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	if std == nil {
+		std, _ = newLogger(DefaultLogConfig)
 	}
+	return std
+}
 
-	fmt.Println("Creating log file")
-	generalLog, err := os.OpenFile(absPath+"/git-churn-log.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+func newLogger(config LogConfig) (*logger, error) {
+	l := &logger{level: config.Level, out: os.Stderr}
+	switch config.Format {
+	case "", "text":
+	case "json":
+		l.json = true
+	default:
+		return nil, fmt.Errorf("unknown log format %q, must be text or json", config.Format)
+	}
+	if config.File != "" {
+		if err := os.MkdirAll(filepath.Dir(config.File), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(config.File, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, err
+		}
+		l.out = f
+		l.closer = f
+	}
+	return l, nil
+}
+
+// ConfigureLogging replaces the logger with one configured by config. Nothing
+// is written to the filesystem unless config.File is set.
+func ConfigureLogging(config LogConfig) error {
+	l, err := newLogger(config)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		os.Exit(1)
+		return err
+	}
+	stdMu.Lock()
+	old := std
+	std = l
+	stdMu.Unlock()
+	if old != nil && old.closer != nil {
+		old.closer.Close()
+	}
+	return nil
+}
+
+// Debug logs msg and the key-value pairs kv at the debug level.
+func Debug(msg string, kv ...interface{}) {
+	getLogger().log(DebugLevel, msg, kv)
+}
+
+// Info logs msg and the key-value pairs kv at the info level.
+func Info(msg string, kv ...interface{}) {
+	getLogger().log(InfoLevel, msg, kv)
+}
+
+// Warn logs msg and the key-value pairs kv at the warn level.
+func Warn(msg string, kv ...interface{}) {
+	getLogger().log(WarnLevel, msg, kv)
+}
+
+// Error logs msg and the key-value pairs kv at the error level.
+func Error(msg string, kv ...interface{}) {
+	getLogger().log(ErrorLevel, msg, kv)
+}
+
+// Enabled tells if the messages of the given level are logged.
+func Enabled(level Level) bool {
+	return level >= getLogger().level
+}
+
+func (l *logger) log(level Level, msg string, kv []interface{}) {
+	if level < l.level {
+		return
+	}
+	now := time.Now()
+	var line string
+	if l.json {
+		line = jsonLine(now, level, msg, kv)
+	} else {
+		line = textLine(now, level, msg, kv)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.out, line)
+}
+
+func textLine(now time.Time, level Level, msg string, kv []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time=%s level=%s msg=%q", now.Format(time.RFC3339Nano), level, msg)
+	for i := 0; i < len(kv); i += 2 {
+		key, value := keyValue(kv, i)
+		s := fmt.Sprint(value)
+		if strings.ContainsAny(s, " \t\n\"=") || s == "" {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, s)
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func jsonLine(now time.Time, level Level, msg string, kv []interface{}) string {
+	fields := map[string]interface{}{
+		"time":  now.Format(time.RFC3339Nano),
+		"level": level.String(),
+		"msg":   msg,
+	}
+	for i := 0; i < len(kv); i += 2 {
+		key, value := keyValue(kv, i)
+		switch value.(type) {
+		case string, bool, int, int64, uint64:
+		default:
+			value = fmt.Sprint(value)
+		}
+		fields[key] = value
+	}
+	data, _ := json.Marshal(fields)
+	return string(data) + "\n"
+}
+
+// keyValue returns the key-value pair at index i of kv.
+func keyValue(kv []interface{}, i int) (string, interface{}) {
+	key := fmt.Sprint(kv[i])
+	if i+1 >= len(kv) {
+		return key, "(missing)"
 	}
-	INFO = log.New(generalLog, "INFO:\t", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile)
-	ERROR = log.New(generalLog, "ERROR:\t", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile)
+	return key, kv[i+1]
 }
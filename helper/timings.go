@@ -9,8 +9,7 @@ func Track(msg string) (string, time.Time) {
 	return msg, time.Now()
 }
 
-// Duration prints the time since start with the msg.
+// Duration logs the time since start with the msg.
 func Duration(msg string, start time.Time) {
-	//PrintInBlue("%v: %v\n", msg, time.Since(start))
-	INFO.Println(msg, time.Since(start))
+	Info("phase finished", "phase", msg, "duration", time.Since(start))
 }
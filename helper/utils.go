@@ -1,20 +1,10 @@
 package helper
 
 import (
-	"fmt"
-	"log"
 	"os"
+	"path/filepath"
 )
 
-func init() {
-	fmt.Println("Checking/creating outputs folder")
-	if _, err := os.Stat("outputs"); os.IsNotExist(err) {
-		fmt.Println("Creating outputs folder")
-
-		os.MkdirAll("outputs", 0777)
-	}
-}
-
 func UniqueElements(input []string) []string {
 	u := make([]string, 0, len(input))
 	m := make(map[string]bool)
@@ -29,14 +19,21 @@ func UniqueElements(input []string) []string {
 	return u
 }
 
+// AppendToFile appends text to the file, creating it and its directory if
+// they do not exist.
 func AppendToFile(fileName, text string) {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		Error("unable to create the output directory", "file", fileName, "error", err)
+		return
+	}
 	f, err := os.OpenFile(fileName,
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Println(err)
+		Error("unable to open the output file", "file", fileName, "error", err)
+		return
 	}
 	defer f.Close()
 	if _, err := f.WriteString(text); err != nil {
-		log.Println(err)
+		Error("unable to write the output file", "file", fileName, "error", err)
 	}
 }
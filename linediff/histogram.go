@@ -0,0 +1,73 @@
+package linediff
+
+// maxChain is the number of occurrences above which a line is not used to
+// align the sides, as in git.
+const maxChain = 64
+
+// histogram marks the changes of the range with the histogram algorithm of
+// git: the longest common region containing the line the least frequent in
+// the first side is aligned, and the ranges around it are diffed
+// recursively. Ranges without common lines rare enough are diffed with Myers'
+// algorithm.
+func (d *differ) histogram(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi = d.trim(aLo, aHi, bLo, bHi)
+	if aLo == aHi || bLo == bHi {
+		d.replace(aLo, aHi, bLo, bHi)
+		return
+	}
+
+	as, ae, bs, be, ok := d.lcsRegion(aLo, aHi, bLo, bHi)
+	if !ok {
+		d.myers(aLo, aHi, bLo, bHi)
+		return
+	}
+	d.histogram(aLo, as, bLo, bs)
+	d.histogram(ae, aHi, be, bHi)
+}
+
+// lcsRegion returns the longest common region, [as, ae) in the first side
+// and [bs, be) in the second one, among the ones containing the lines the
+// least frequent in the first side.
+func (d *differ) lcsRegion(aLo, aHi, bLo, bHi int) (as, ae, bs, be int, ok bool) {
+	occurrences := make(map[int][]int)
+	for i := aLo; i < aHi; i++ {
+		occurrences[d.a[i]] = append(occurrences[d.a[i]], i)
+	}
+
+	lowest := maxChain
+	for j := bLo; j < bHi; {
+		positions := occurrences[d.b[j]]
+		if len(positions) == 0 || len(positions) > lowest {
+			j++
+			continue
+		}
+		next := j + 1
+		for _, i := range positions {
+			s, e, sb, eb := i, i+1, j, j+1
+			count := len(positions)
+			for s > aLo && sb > bLo && d.a[s-1] == d.b[sb-1] {
+				s--
+				sb--
+				if c := len(occurrences[d.a[s]]); c < count {
+					count = c
+				}
+			}
+			for e < aHi && eb < bHi && d.a[e] == d.b[eb] {
+				if c := len(occurrences[d.a[e]]); c < count {
+					count = c
+				}
+				e++
+				eb++
+			}
+			if eb > next {
+				next = eb
+			}
+			if !ok || e-s > ae-as || count < lowest {
+				as, ae, bs, be, ok = s, e, sb, eb, true
+				lowest = count
+			}
+		}
+		j = next
+	}
+	return as, ae, bs, be, ok
+}
@@ -0,0 +1,166 @@
+// Package linediff computes line diffs with the Myers, patience and histogram
+// algorithms of git.
+package linediff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Algorithm is a line diff algorithm.
+type Algorithm string
+
+// The algorithms, Myers being the default one of git.
+const (
+	Myers     Algorithm = "myers"
+	Patience  Algorithm = "patience"
+	Histogram Algorithm = "histogram"
+)
+
+// Algorithms are all the algorithms.
+var Algorithms = []Algorithm{Myers, Patience, Histogram}
+
+// ParseAlgorithm returns the algorithm with the given name.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for _, a := range Algorithms {
+		if strings.EqualFold(name, string(a)) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown diff algorithm %q, must be myers, patience or histogram", name)
+}
+
+// Op is the operation of a hunk.
+type Op int8
+
+// The operations, with the values of the diffs of go-git.
+const (
+	Delete Op = -1
+	Equal  Op = 0
+	Insert Op = 1
+)
+
+// Hunk is a run of lines equal, inserted or deleted.
+type Hunk struct {
+	Op    Op
+	Lines []string
+}
+
+// Do returns the hunks turning src into dst. Within a change, deletions
+// come before insertions.
+func (a Algorithm) Do(src, dst string) []Hunk {
+	return a.DoLines(Split(src), Split(dst))
+}
+
+// DoLines is like Do for texts already split into lines.
+func (a Algorithm) DoLines(src, dst []string) []Hunk {
+	d := newDiffer(src, dst)
+	switch a {
+	case Patience:
+		d.patience(0, len(d.a), 0, len(d.b))
+	case Histogram:
+		d.histogram(0, len(d.a), 0, len(d.b))
+	default:
+		d.myers(0, len(d.a), 0, len(d.b))
+	}
+	return d.hunks(src, dst)
+}
+
+// Split splits text into lines, keeping their line feeds.
+func Split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// differ marks the lines of a deleted and the lines of b inserted. The lines
+// are interned so that they are compared as integers.
+type differ struct {
+	a, b    []int
+	deleted []bool
+	added   []bool
+}
+
+func newDiffer(src, dst []string) *differ {
+	ids := make(map[string]int, len(src))
+	intern := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+	return &differ{
+		a:       intern(src),
+		b:       intern(dst),
+		deleted: make([]bool, len(src)),
+		added:   make([]bool, len(dst)),
+	}
+}
+
+// trim returns the range without its common prefix and suffix.
+func (d *differ) trim(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	return aLo, aHi, bLo, bHi
+}
+
+// replace marks the whole range as changed.
+func (d *differ) replace(aLo, aHi, bLo, bHi int) {
+	for i := aLo; i < aHi; i++ {
+		d.deleted[i] = true
+	}
+	for j := bLo; j < bHi; j++ {
+		d.added[j] = true
+	}
+}
+
+// hunks returns the hunks of the lines marked.
+func (d *differ) hunks(src, dst []string) []Hunk {
+	var result []Hunk
+	add := func(op Op, lines []string) {
+		if len(lines) != 0 {
+			result = append(result, Hunk{Op: op, Lines: lines})
+		}
+	}
+	i, j := 0, 0
+	for i < len(src) || j < len(dst) {
+		if i == len(src) || j == len(dst) {
+			// what is left of one side can only be deleted or inserted
+			d.replace(i, len(src), j, len(dst))
+		}
+		start := i
+		for i < len(src) && d.deleted[i] {
+			i++
+		}
+		add(Delete, src[start:i])
+		start = j
+		for j < len(dst) && d.added[j] {
+			j++
+		}
+		add(Insert, dst[start:j])
+		start = j
+		for i < len(src) && j < len(dst) && !d.deleted[i] && !d.added[j] {
+			i++
+			j++
+		}
+		add(Equal, dst[start:j])
+	}
+	return result
+}
@@ -0,0 +1,253 @@
+package linediff_test
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/linediff"
+)
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, a := range linediff.Algorithms {
+		if got, err := linediff.ParseAlgorithm(strings.ToUpper(string(a))); err != nil || got != a {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v, want %q", strings.ToUpper(string(a)), got, err, a)
+		}
+	}
+	if _, err := linediff.ParseAlgorithm("minimal"); err == nil {
+		t.Error("ParseAlgorithm(\"minimal\") succeeded, want an error")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\n\nb", []string{"a\n", "\n", "b"}},
+	}
+	for _, test := range tests {
+		if got := linediff.Split(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Split(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+// frobSrc and frobDst are the example of the patience diff: a function is
+// added before another one and a third one is removed.
+const (
+	frobSrc = `#include <stdio.h>
+
+// Frobs foo heartily
+int frobnitz(int foo)
+{
+    int i;
+    for(i = 0; i < 10; i++)
+    {
+        printf("Your answer is: ");
+        printf("%d\n", foo);
+    }
+}
+
+int fact(int n)
+{
+    if(n > 1)
+    {
+        return fact(n-1) * n;
+    }
+    return 1;
+}
+
+int main(int argc, char **argv)
+{
+    frobnitz(fact(10));
+}
+`
+	frobDst = `#include <stdio.h>
+
+int fib(int n)
+{
+    if(n > 2)
+    {
+        return fib(n-1) + fib(n-2);
+    }
+    return 1;
+}
+
+// Frobs foo heartily
+int frobnitz(int foo)
+{
+    int i;
+    for(i = 0; i < 10; i++)
+    {
+        printf("%d\n", foo);
+    }
+}
+
+int main(int argc, char **argv)
+{
+    frobnitz(fib(10));
+}
+`
+)
+
+// ops returns the operation and the number of lines of every hunk, e.g.
+// "=2 +9 -1".
+func ops(hunks []linediff.Hunk) string {
+	var s []string
+	for _, h := range hunks {
+		s = append(s, fmt.Sprintf("%c%d", "-=+"[h.Op+1], len(h.Lines)))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name     string
+		src, dst string
+		want     string
+	}{
+		{"same", "a\nb\n", "a\nb\n", "=2"},
+		{"empty", "", "", ""},
+		{"added", "", "a\nb\n", "+2"},
+		{"removed", "a\nb\n", "", "-2"},
+		{"changed", "a\nb\nc\n", "a\nB\nc\n", "=1 -1 +1 =1"},
+		{"no final line feed", "a\nb", "a\nb\n", "=1 -1 +1"},
+		{"frobnitz", frobSrc, frobDst, "=2 +9 =6 -1 =4 -9 =2 -1 +1 =1"},
+	}
+	for _, a := range linediff.Algorithms {
+		for _, test := range tests {
+			if got := ops(a.Do(test.src, test.dst)); got != test.want {
+				t.Errorf("%s: %s: hunks %q, want %q", a, test.name, got, test.want)
+			}
+		}
+	}
+}
+
+// randomLines returns n lines out of an alphabet of size lines, so that
+// the smaller it is the more lines repeat.
+func randomLines(rnd *rand.Rand, n, size int) []string {
+	result := make([]string, n)
+	for i := range result {
+		result[i] = fmt.Sprintf("%d\n", rnd.Intn(size))
+	}
+	return result
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// The hunks turn the source into the destination, with no empty hunks, no
+// two consecutive hunks of the same operation and the deletions of a change
+// before its insertions. Myers' diffs are the shortest.
+func TestDoRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for round := 0; round < 500; round++ {
+		size := 2 + rnd.Intn(10)
+		src, dst := randomLines(rnd, rnd.Intn(40), size), randomLines(rnd, rnd.Intn(40), size)
+		for _, a := range linediff.Algorithms {
+			hunks := a.DoLines(src, dst)
+			var gotSrc, gotDst []string
+			changed := 0
+			for i, h := range hunks {
+				if len(h.Lines) == 0 {
+					t.Fatalf("%s: %q to %q: empty hunk %d", a, src, dst, i)
+				}
+				if i > 0 && (hunks[i-1].Op == h.Op || hunks[i-1].Op == linediff.Insert && h.Op == linediff.Delete) {
+					t.Fatalf("%s: %q to %q: hunk %d %v after %v", a, src, dst, i, h.Op, hunks[i-1].Op)
+				}
+				if h.Op != linediff.Insert {
+					gotSrc = append(gotSrc, h.Lines...)
+				}
+				if h.Op != linediff.Delete {
+					gotDst = append(gotDst, h.Lines...)
+				}
+				if h.Op != linediff.Equal {
+					changed += len(h.Lines)
+				}
+			}
+			if strings.Join(gotSrc, "") != strings.Join(src, "") || strings.Join(gotDst, "") != strings.Join(dst, "") {
+				t.Fatalf("%s: %q to %q: hunks %q", a, src, dst, hunks)
+			}
+			if want := len(src) + len(dst) - 2*lcs(src, dst); a == linediff.Myers && changed != want {
+				t.Fatalf("%s: %q to %q: %d lines changed, want %d", a, src, dst, changed, want)
+			}
+		}
+	}
+}
+
+// benchmarkTexts returns a file of n lines and a version of it with a few
+// blocks of lines changed, inserted, deleted and moved.
+func benchmarkTexts(n int) (string, string) {
+	rnd := rand.New(rand.NewSource(1))
+	src := make([]string, n)
+	for i := range src {
+		// code repeats braces and blank lines
+		switch rnd.Intn(5) {
+		case 0:
+			src[i] = "}\n"
+		case 1:
+			src[i] = "\n"
+		default:
+			src[i] = fmt.Sprintf("line %d\n", rnd.Intn(n))
+		}
+	}
+	dst := append([]string(nil), src...)
+	for edit := 0; edit < n/50; edit++ {
+		at := rnd.Intn(len(dst))
+		end := at + 1 + rnd.Intn(5)
+		if end > len(dst) {
+			end = len(dst)
+		}
+		switch rnd.Intn(3) {
+		case 0:
+			for i := at; i < end; i++ {
+				dst[i] = fmt.Sprintf("changed %d\n", rnd.Int())
+			}
+		case 1:
+			dst = append(dst[:at], dst[end:]...)
+		default:
+			block := append([]string(nil), dst[at:end]...)
+			dst = append(dst[:at], dst[end:]...)
+			to := rnd.Intn(len(dst) + 1)
+			dst = append(dst[:to], append(block, dst[to:]...)...)
+		}
+	}
+	return strings.Join(src, ""), strings.Join(dst, "")
+}
+
+func BenchmarkDo(b *testing.B) {
+	for _, n := range []int{100, 5000} {
+		src, dst := benchmarkTexts(n)
+		for _, a := range linediff.Algorithms {
+			b.Run(fmt.Sprintf("%s/%d", a, n), func(b *testing.B) {
+				b.SetBytes(int64(len(src) + len(dst)))
+				for i := 0; i < b.N; i++ {
+					a.Do(src, dst)
+				}
+			})
+		}
+	}
+}
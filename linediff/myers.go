@@ -0,0 +1,105 @@
+package linediff
+
+// myers marks the changes of the range with Myers' O(ND) algorithm, in linear
+// space: the range is split at the middle snake of a shortest edit script and
+// both halves are diffed recursively.
+func (d *differ) myers(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi = d.trim(aLo, aHi, bLo, bHi)
+	if aLo == aHi || bLo == bHi {
+		d.replace(aLo, aHi, bLo, bHi)
+		return
+	}
+	x, y, ok := d.middleSnake(aLo, aHi, bLo, bHi)
+	if !ok {
+		d.replace(aLo, aHi, bLo, bHi)
+		return
+	}
+	d.myers(aLo, x, bLo, y)
+	d.myers(x, aHi, y, bHi)
+}
+
+// middleSnake runs the forward and the backward searches of a shortest edit
+// script of the range until they overlap, and returns where.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	length := 2*maxD + 1
+	forward := make([]int, length)
+	backward := make([]int, length)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+	delta := n - m
+	// the searches overlap on a forward step if delta is odd, on a backward
+	// one otherwise
+	front := delta%2 != 0
+	// the diagonals out of the range, on either side
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -step || k1 != step && forward[k1Offset-1] < forward[k1Offset+1] {
+				x1 = forward[k1Offset+1]
+			} else {
+				x1 = forward[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			forward[k1Offset] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < length && backward[k2Offset] != -1 {
+					if x1 >= n-backward[k2Offset] {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -step || k2 != step && backward[k2Offset-1] < backward[k2Offset+1] {
+				x2 = backward[k2Offset+1]
+			} else {
+				x2 = backward[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			backward[k2Offset] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < length && forward[k1Offset] != -1 {
+					x1 := forward[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
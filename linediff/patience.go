@@ -0,0 +1,88 @@
+package linediff
+
+import "sort"
+
+// patience marks the changes of the range with the patience algorithm: the
+// lines unique in both sides are aligned along their longest common
+// subsequence, and the ranges between them are diffed recursively. Ranges
+// without unique common lines are diffed with Myers' algorithm.
+func (d *differ) patience(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi = d.trim(aLo, aHi, bLo, bHi)
+	if aLo == aHi || bLo == bHi {
+		d.replace(aLo, aHi, bLo, bHi)
+		return
+	}
+
+	anchors := d.uniqueAnchors(aLo, aHi, bLo, bHi)
+	if len(anchors) == 0 {
+		d.myers(aLo, aHi, bLo, bHi)
+		return
+	}
+	for _, anchor := range anchors {
+		d.patience(aLo, anchor.a, bLo, anchor.b)
+		aLo, bLo = anchor.a+1, anchor.b+1
+	}
+	d.patience(aLo, aHi, bLo, bHi)
+}
+
+type anchor struct {
+	a, b int
+}
+
+// uniqueAnchors returns the longest increasing sequence of the lines that
+// appear once in both sides of the range.
+func (d *differ) uniqueAnchors(aLo, aHi, bLo, bHi int) []anchor {
+	type occurrence struct {
+		countA, countB int
+		a, b           int
+	}
+	lines := make(map[int]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		o, ok := lines[d.a[i]]
+		if !ok {
+			o = &occurrence{}
+			lines[d.a[i]] = o
+		}
+		o.countA++
+		o.a = i
+	}
+	for j := bLo; j < bHi; j++ {
+		if o, ok := lines[d.b[j]]; ok {
+			o.countB++
+			o.b = j
+		}
+	}
+	var unique []anchor
+	for _, o := range lines {
+		if o.countA == 1 && o.countB == 1 {
+			unique = append(unique, anchor{o.a, o.b})
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].a < unique[j].a })
+
+	// patience sorting: piles[k] is the last anchor of the lowest ending
+	// increasing sequence of length k+1 found so far
+	var piles []int
+	prev := make([]int, len(unique))
+	for i, u := range unique {
+		k := sort.Search(len(piles), func(k int) bool { return unique[piles[k]].b > u.b })
+		if k > 0 {
+			prev[i] = piles[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(piles) {
+			piles = append(piles, i)
+		} else {
+			piles[k] = i
+		}
+	}
+	if len(piles) == 0 {
+		return nil
+	}
+	result := make([]anchor, len(piles))
+	for i, k := len(piles)-1, piles[len(piles)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = unique[k]
+	}
+	return result
+}
@@ -0,0 +1,273 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// The policies to verify the host keys of SSH remotes.
+const (
+	// KnownHostsStrict rejects the hosts that are not in the known_hosts
+	// files.
+	KnownHostsStrict = "strict"
+	// KnownHostsAcceptNew adds the unknown hosts to the first known_hosts
+	// file, and rejects the known hosts whose key changed.
+	KnownHostsAcceptNew = "accept-new"
+	// KnownHostsInsecure accepts any host key.
+	KnownHostsInsecure = "insecure"
+)
+
+// The environment variables credentials are read from when they are not set.
+const (
+	EnvUsername      = "GIT_CHURN_USERNAME"
+	EnvPassword      = "GIT_CHURN_PASSWORD"
+	EnvToken         = "GIT_CHURN_TOKEN"
+	EnvSSHKey        = "GIT_CHURN_SSH_KEY"
+	EnvSSHPassphrase = "GIT_CHURN_SSH_PASSPHRASE"
+)
+
+// AuthOptions describes the credentials used to clone remote repositories.
+type AuthOptions struct {
+	// Username and Password are used for HTTP basic authentication.
+	Username string
+	Password string
+	// Token is sent as the password of HTTP basic authentication, with
+	// Username or "git" as the user name.
+	Token string
+	// CredentialHelper asks the git credential helpers for the HTTP
+	// credentials when none are given.
+	CredentialHelper bool
+
+	// SSHKeyFile is the private key used for SSH remotes, decrypted with
+	// SSHKeyPassphrase if it is encrypted.
+	SSHKeyFile       string
+	SSHKeyPassphrase string
+	// SSHAgent uses the keys of the running ssh-agent for SSH remotes. It is
+	// also used when no SSHKeyFile is given, and exclusive with it.
+	SSHAgent bool
+	// KnownHosts is the policy to verify the host keys of SSH remotes,
+	// KnownHostsStrict if empty.
+	KnownHosts string
+	// KnownHostsFiles are the known_hosts files, the ones listed in
+	// $SSH_KNOWN_HOSTS, or else the ones of the user and the system, if
+	// empty.
+	KnownHostsFiles []string
+}
+
+// Auth holds the credentials used by every clone of a remote repository.
+var Auth AuthOptions
+
+// LoadEnv fills the credentials that are not set from the environment.
+func (a *AuthOptions) LoadEnv() {
+	setFromEnv(&a.Username, EnvUsername)
+	setFromEnv(&a.Password, EnvPassword)
+	setFromEnv(&a.Token, EnvToken)
+	setFromEnv(&a.SSHKeyFile, EnvSSHKey)
+	setFromEnv(&a.SSHKeyPassphrase, EnvSSHPassphrase)
+}
+
+func setFromEnv(value *string, name string) {
+	if *value == "" {
+		*value = os.Getenv(name)
+	}
+}
+
+// cloneOptions returns the options to clone the repository at repoUrl with
+// the credentials in Auth.
+func cloneOptions(repoUrl string) (*git.CloneOptions, error) {
+	auth, err := Auth.method(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &git.CloneOptions{
+		URL:  repoUrl,
+		Auth: auth,
+	}, nil
+}
+
+// method returns the authentication method for the repository at repoUrl,
+// nil if it needs none.
+func (a *AuthOptions) method(repoUrl string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	switch ep.Protocol {
+	case "http", "https":
+		return a.httpMethod(ep)
+	case "ssh":
+		return a.sshMethod(ep)
+	default:
+		return nil, nil
+	}
+}
+
+func (a *AuthOptions) httpMethod(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	switch {
+	case a.Token != "":
+		username := a.Username
+		if username == "" {
+			username = "git"
+		}
+		return &http.BasicAuth{Username: username, Password: a.Token}, nil
+	case a.Username != "" || a.Password != "":
+		return &http.BasicAuth{Username: a.Username, Password: a.Password}, nil
+	case ep.User != "" || ep.Password != "":
+		// the credentials of the URL, which is stored without them
+		return &http.BasicAuth{Username: ep.User, Password: ep.Password}, nil
+	case a.CredentialHelper:
+		return credentialFill(ep)
+	default:
+		return nil, nil
+	}
+}
+
+// credentialFill asks the git credential helpers for the credentials of ep,
+// like git does before connecting to an HTTP remote.
+func credentialFill(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	host := ep.Host
+	if ep.Port != 0 {
+		host = fmt.Sprintf("%s:%d", ep.Host, ep.Port)
+	}
+	input := fmt.Sprintf("protocol=%s\nhost=%s\npath=%s\n\n", ep.Protocol, host, strings.TrimPrefix(ep.Path, "/"))
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git credential fill: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	auth := new(http.BasicAuth)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			auth.Username = kv[1]
+		case "password":
+			auth.Password = kv[1]
+		}
+	}
+	if auth.Username == "" && auth.Password == "" {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+func (a *AuthOptions) sshMethod(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	if a.SSHKeyFile != "" && a.SSHAgent {
+		return nil, errors.New("an ssh key and the ssh-agent are exclusive")
+	}
+	user := ep.User
+	if user == "" {
+		user = "git"
+	}
+	callback, err := a.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	if a.SSHKeyFile != "" {
+		auth, err := ssh.NewPublicKeysFromFile(user, a.SSHKeyFile, a.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("ssh key %s: %v", a.SSHKeyFile, err)
+		}
+		auth.HostKeyCallback = callback
+		return auth, nil
+	}
+	auth, err := ssh.NewSSHAgentAuth(user)
+	if err != nil {
+		return nil, fmt.Errorf("ssh-agent: %v", err)
+	}
+	auth.HostKeyCallback = callback
+	return auth, nil
+}
+
+// hostKeyCallback returns the callback that verifies the host keys according
+// to the KnownHosts policy.
+func (a *AuthOptions) hostKeyCallback() (gossh.HostKeyCallback, error) {
+	switch a.KnownHosts {
+	case KnownHostsInsecure:
+		return gossh.InsecureIgnoreHostKey(), nil
+	case "", KnownHostsStrict, KnownHostsAcceptNew:
+	default:
+		return nil, fmt.Errorf("unknown known_hosts policy %q", a.KnownHosts)
+	}
+
+	files := a.KnownHostsFiles
+	if len(files) == 0 {
+		files = filepath.SplitList(os.Getenv("SSH_KNOWN_HOSTS"))
+	}
+	if len(files) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		files = []string{filepath.Join(home, ".ssh", "known_hosts"), "/etc/ssh/ssh_known_hosts"}
+	}
+	if a.KnownHosts == KnownHostsAcceptNew {
+		return acceptNewCallback(files)
+	}
+	return ssh.NewKnownHostsCallback(files...)
+}
+
+// acceptNewCallback verifies the host keys against the known_hosts files,
+// adding the unknown hosts to the first of them.
+func acceptNewCallback(files []string) (gossh.HostKeyCallback, error) {
+	if err := os.MkdirAll(filepath.Dir(files[0]), 0700); err != nil {
+		return nil, err
+	}
+	var existing []string
+	for i, file := range files {
+		if i == 0 {
+			f, err := os.OpenFile(file, os.O_CREATE|os.O_RDONLY, 0600)
+			if err != nil {
+				return nil, err
+			}
+			f.Close()
+		}
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	known, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) != 0 {
+			return err
+		}
+		f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}, nil
+}
@@ -0,0 +1,264 @@
+package metrics_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/ashishgalagali/go-git-churn/synth"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// remoteRepo builds a small repository in the directory root/repo.
+func remoteRepo(t *testing.T) (root string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	spec := synth.DefaultSpec
+	spec.Commits = 5
+	if _, err := synth.BuildDir(filepath.Join(root, "repo"), spec); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// withAuth sets metrics.Auth for the test.
+func withAuth(t *testing.T, auth metrics.AuthOptions) {
+	saved := metrics.Auth
+	metrics.Auth = auth
+	t.Cleanup(func() { metrics.Auth = saved })
+}
+
+// httpBackend serves the repositories below root with git http-backend to
+// the clients authenticated as user and password.
+func httpBackend(t *testing.T, root, user, password string) *httptest.Server {
+	backend := &cgi.Handler{
+		Path: "git",
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	var err error
+	if backend.Path, err = exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestHTTPAuth(t *testing.T) {
+	s := httpBackend(t, remoteRepo(t), "git", "secret")
+	url := s.URL + "/repo/.git"
+	tests := []struct {
+		name string
+		auth metrics.AuthOptions
+		ok   bool
+	}{
+		{"token", metrics.AuthOptions{Token: "secret"}, true},
+		{"password", metrics.AuthOptions{Username: "git", Password: "secret"}, true},
+		{"wrong token", metrics.AuthOptions{Token: "wrong"}, false},
+		{"none", metrics.AuthOptions{}, false},
+	}
+	for _, test := range tests {
+		withAuth(t, test.auth)
+		_, err := metrics.OpenRepo(context.Background(), url)
+		if test.ok && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%s: cloned, want an error", test.name)
+		}
+	}
+}
+
+// sshServer serves the repositories below root with git-upload-pack to the
+// clients authenticated with the key clientKey, and returns its address and
+// host key.
+func sshServer(t *testing.T, root string, clientKey gossh.PublicKey) (string, gossh.PublicKey) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := gossh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config, root)
+		}
+	}()
+	return l.Addr().String(), hostSigner.PublicKey()
+}
+
+// serveSSH runs the git-upload-pack commands of the sessions of conn.
+func serveSSH(conn net.Conn, config *gossh.ServerConfig, root string) {
+	_, channels, requests, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					req.Reply(false, nil)
+					continue
+				}
+				command := string(req.Payload[4:])
+				path := strings.Trim(strings.TrimPrefix(command, "git-upload-pack "), "'")
+				if !strings.HasPrefix(command, "git-upload-pack ") {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				cmd := exec.Command("git-upload-pack", filepath.Join(root, path))
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = channel, channel, channel.Stderr()
+				status := make([]byte, 4)
+				if err := cmd.Run(); err != nil {
+					binary.BigEndian.PutUint32(status, 1)
+				}
+				channel.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+// clientKey writes a new private key to a file and returns its name and its
+// public key.
+func clientKey(t *testing.T, dir string) (string, gossh.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "id_rsa")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := gossh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name, pub
+}
+
+func TestSSHAuth(t *testing.T) {
+	root := remoteRepo(t)
+	keyFile, pub := clientKey(t, root)
+	addr, hostKey := sshServer(t, root, pub)
+	url := "ssh://git@" + addr + "/repo/.git"
+
+	knownHosts := filepath.Join(root, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	if err := ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(root, "empty_known_hosts")
+	if err := ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	withAuth(t, metrics.AuthOptions{SSHKeyFile: keyFile, KnownHostsFiles: []string{knownHosts}})
+	if _, err := metrics.OpenRepo(context.Background(), url); err != nil {
+		t.Errorf("known host: %v", err)
+	}
+
+	withAuth(t, metrics.AuthOptions{SSHKeyFile: keyFile, KnownHostsFiles: []string{empty}})
+	if _, err := metrics.OpenRepo(context.Background(), url); err == nil {
+		t.Error("unknown host: cloned, want an error")
+	}
+
+	// without files, the ones in $SSH_KNOWN_HOSTS are used
+	saved, ok := os.LookupEnv("SSH_KNOWN_HOSTS")
+	os.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+	defer func() {
+		if ok {
+			os.Setenv("SSH_KNOWN_HOSTS", saved)
+		} else {
+			os.Unsetenv("SSH_KNOWN_HOSTS")
+		}
+	}()
+	withAuth(t, metrics.AuthOptions{SSHKeyFile: keyFile})
+	if _, err := metrics.OpenRepo(context.Background(), url); err != nil {
+		t.Errorf("host in $SSH_KNOWN_HOSTS: %v", err)
+	}
+	os.Setenv("SSH_KNOWN_HOSTS", empty)
+	if _, err := metrics.OpenRepo(context.Background(), url); err == nil {
+		t.Error("host not in $SSH_KNOWN_HOSTS: cloned, want an error")
+	}
+
+	accepted := filepath.Join(root, "accepted_known_hosts")
+	withAuth(t, metrics.AuthOptions{SSHKeyFile: keyFile, KnownHosts: metrics.KnownHostsAcceptNew,
+		KnownHostsFiles: []string{accepted}})
+	if _, err := metrics.OpenRepo(context.Background(), url); err != nil {
+		t.Errorf("accept-new: %v", err)
+	}
+	if data, err := ioutil.ReadFile(accepted); err != nil || !strings.Contains(string(data), line) {
+		t.Errorf("accept-new: known_hosts %q, want %q", data, line)
+	}
+
+	withAuth(t, metrics.AuthOptions{SSHKeyFile: keyFile, SSHAgent: true, KnownHostsFiles: []string{knownHosts}})
+	if _, err := metrics.OpenRepo(context.Background(), url); err == nil ||
+		!strings.Contains(err.Error(), "exclusive") {
+		t.Errorf("key and agent: %v, want them exclusive", err)
+	}
+}
@@ -2,13 +2,15 @@ package metrics
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/ashishgalagali/go-git-churn/helper"
+	"github.com/ashishgalagali/go-git-churn/linediff"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/utils/diff"
+	"io"
 	"strings"
 	"time"
 )
@@ -20,13 +22,29 @@ type BlameResult struct {
 	// Rev (Revision) is the hash of the specified Commit used to generate this result.
 	Rev plumbing.Hash
 	// Lines contains every line with its authorship.
-	Lines  []*Line
+	Lines []*Line
+	// Churns is the churn of every revision if KeepChurns is set.
 	Churns []Churn
 }
 
+// KeepChurns tells Blame and BlameTips to return the churn of every revision,
+// e.g. to aggregate it. Otherwise it is only written to the output file, and
+// not kept in memory.
+var KeepChurns bool
+
 // Blame returns a BlameResult with the information about the last author of
 // each line from file `path` at commit `c`.
-func Blame(c *object.Commit, path string, lastCommitId string) (*BlameResult, error) {
+//
+// If ctx is done before all the revisions are processed, the output is closed
+// with an Interruption record and an *InterruptedError is returned.
+func Blame(ctx context.Context, c *object.Commit, path string, lastCommitId string) (*BlameResult, error) {
+	return BlameTips(ctx, []Tip{{Commit: c}}, path, lastCommitId)
+}
+
+// BlameTips is like Blame for the history of several tips. The commits they
+// share are processed once, and the churn of every commit lists the refs of
+// the tips it is reachable from, unless none of the tips has a ref.
+func BlameTips(ctx context.Context, tips []Tip, path string, lastCommitId string) (*BlameResult, error) {
 	// The file to blame is identified by the input arguments:
 	// commit and path. commit is a Commit object obtained from a Repository. Path
 	// represents a path to a specific file contained into the repository.
@@ -61,20 +79,28 @@ func Blame(c *object.Commit, path string, lastCommitId string) (*BlameResult, er
 	// 2. It is using much more memory than needed, see the TODOs below.
 
 	b := new(blame)
-	b.fRev = c
+	b.fRev = tips[0].Commit
+	b.tips = tips
 	//b.pRev = p
 	// TODO: filter is path is not empty
 	b.path = path
 	b.lastCommitId = lastCommitId
+	b.opFileName = "outputs/output_" + time.Now().UTC().Format("2006-01-02T15:04:05-0700") + ".json"
+	var churns []Churn
+	if KeepChurns {
+		b.onChurn = func(churn Churn) {
+			churns = append(churns, churn)
+		}
+	}
 
 	// get all the file revisions
-	if err := b.fillRevs(); err != nil {
+	if err := b.fillRevs(ctx); err != nil {
 		return nil, err
 	}
 
 	// calculate the line tracking graph and fill in
 	// file contents in data.
-	if err := b.fillGraphAndData(); err != nil {
+	if err := b.fillGraphAndData(ctx); err != nil {
 		return nil, err
 	}
 
@@ -97,30 +123,103 @@ func Blame(c *object.Commit, path string, lastCommitId string) (*BlameResult, er
 	//	return nil, err
 	//}
 
-	Churns := make([]Churn, len(b.revs))
-	for i := 0; i < len(b.revs); i++ {
-		Churns[i] = Churn{
-			CommitID:      b.revs[i].Hash.String(),
-			CommitAuthor:  b.revs[i].Author.Email,
-			Date:          b.revs[i].Author.When.String(),
-			CommitMessage: b.revs[i].Message,
-			ChurnFiles:    b.ChurnFiles[i],
-		}
-	}
-
 	return &BlameResult{
 		Path: path,
-		Rev:  c.Hash,
+		Rev:  b.fRev.Hash,
 		//Lines:  lines,
-		Churns: Churns,
+		Churns: churns,
 	}, nil
 }
 
+// ChurnOptions describes how Churns computes the churn of a history.
+type ChurnOptions struct {
+	// Path, if not empty, is the only file whose churn is computed.
+	Path string
+	// Paths, if not empty, restricts the churn files to the ones below any
+	// of these paths.
+	Paths []string
+	// From, if not nil, excludes the revisions reachable from it. They are
+	// still walked to find the origin of the lines.
+	From *object.Commit
+	// Tips, if not empty, are walked instead of the commit given to Churns.
+	Tips []Tip
+	// Since and Until, if not zero, exclude the revisions authored before
+	// and after them. They are still walked to find the origin of the lines.
+	Since, Until time.Time
+	// Moves, if not nil, replaces the package Moves.
+	Moves *MoveOptions
+}
+
+// Churns returns the churn of every revision in the history of commit c.
+func Churns(ctx context.Context, c *object.Commit, opts *ChurnOptions) ([]Churn, error) {
+	if opts == nil {
+		opts = new(ChurnOptions)
+	}
+	exclude := make(map[string]struct{})
+	if opts.From != nil {
+		iter := object.NewCommitPreorderIter(opts.From, nil, nil)
+		err := iter.ForEach(func(commit *object.Commit) error {
+			exclude[commit.Hash.String()] = struct{}{}
+			return ctx.Err()
+		})
+		if err != nil {
+			return nil, wrapError(err, opts.From.Hash.String(), "")
+		}
+	}
+
+	var result []Churn
+	b := new(blame)
+	b.fRev = c
+	if len(opts.Tips) != 0 {
+		b.fRev = opts.Tips[0].Commit
+		b.tips = opts.Tips
+	}
+	b.path = opts.Path
+	b.moves = opts.Moves
+	b.onChurn = func(churn Churn) {
+		when := b.revs[b.commitIndexMap[churn.CommitID]].Author.When
+		if !opts.Since.IsZero() && when.Before(opts.Since) || !opts.Until.IsZero() && when.After(opts.Until) {
+			return
+		}
+		if _, ok := exclude[churn.CommitID]; !ok {
+			churn.ChurnFiles = filterChurnFiles(churn.ChurnFiles, opts.Paths)
+			result = append(result, churn)
+		}
+	}
+	if err := b.fillRevs(ctx); err != nil {
+		return nil, err
+	}
+	if err := b.fillGraphAndData(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// filterChurnFiles returns the files below any of the given paths.
+func filterChurnFiles(files []ChurnFile, paths []string) []ChurnFile {
+	if len(paths) == 0 {
+		return files
+	}
+	result := make([]ChurnFile, 0, len(files))
+	for _, f := range files {
+		for _, p := range paths {
+			if hasPathPrefix(f.FileName, p) {
+				result = append(result, f)
+				break
+			}
+		}
+	}
+	return result
+}
+
 type ChurnFile struct {
 	FileName  string
 	SelfChurn []int
 	//TODO:
 	InteractiveChurn map[string][]int // Hash of authors and count
+	// Moved are the lines deleted that were moved elsewhere in the file,
+	// they keep their origin and are not churn.
+	Moved []int `json:",omitempty"`
 }
 
 type Churn struct {
@@ -129,6 +228,27 @@ type Churn struct {
 	Date          string
 	CommitMessage string
 	ChurnFiles    []ChurnFile
+	// Refs are the refs the commit is reachable from, when several tips
+	// are walked.
+	Refs    []string `json:",omitempty"`
+	Parents []string
+	Stats   CommitStats
+	// Ignored tells that the commit is ignored, its changes are attributed
+	// to the commits of the lines they match and it has no churn.
+	Ignored bool `json:",omitempty"`
+	// Issues and PullRequests are the ids of the issues and pull requests
+	// the message of the commit links to.
+	Issues       []string `json:",omitempty"`
+	PullRequests []string `json:",omitempty"`
+}
+
+// parentHashes returns the hashes of the parents of c.
+func parentHashes(c *object.Commit) []string {
+	result := make([]string, len(c.ParentHashes))
+	for i, h := range c.ParentHashes {
+		result[i] = h.String()
+	}
+	return result
 }
 
 // Line values represent the contents and author of a line in BlamedResult values.
@@ -183,6 +303,12 @@ type blame struct {
 	lastCommitId string
 	// the commit of the final revision of the file to blame
 	fRev *object.Commit
+	// the tips walked instead of fRev, if any, and the refs of the tips every
+	// revision is reachable from
+	tips []Tip
+	refs map[plumbing.Hash][]string
+	// moves, if not nil, replaces the package Moves
+	moves *MoveOptions
 
 	// the commit of the parent revision of the file to blame till
 	//pRev *object.Commit
@@ -199,18 +325,74 @@ type blame struct {
 	commitIndexMap map[string]int
 
 	ChurnFiles [][]ChurnFile
+
+	// the file the churn of every revision is written to, nothing is
+	// written if it is empty
+	opFileName string
+
+	// onDelete, if not nil, is called for every line of file that is
+	// deleted, with the commit the line was introduced by and the commit
+	// that deleted it.
+	onDelete func(file string, origin, by *object.Commit)
+
+	// onChurn, if not nil, is called with the churn of every revision.
+	onChurn func(churn Churn)
+
+	// onDiff, if not nil, is called with the hunks of the diff of every file
+	// of a revision not ignored against its parent, and with the origins of
+	// the lines of the file in the parent.
+	onDiff func(file string, hunks []linediff.Hunk, origins []*object.Commit, by *object.Commit)
+
+	// skip, if not nil, tells if a revision is ignored given the diffs of
+	// its files.
+	skip func(rev *object.Commit, diffs []fileDiff) bool
+
+	// stats, if not nil, collects the stats of the run
+	stats *Stats
+
+	// the indices in revs of the ignored revisions
+	ignored map[int]bool
 }
 
 // calculate the history of a file "path", starting from commit "from", sorted by commit date.
-func (b *blame) fillRevs() error {
+func (b *blame) fillRevs(ctx context.Context) error {
+	defer helper.Duration(helper.Track("history walk"))
+	b.stats = statsFrom(ctx)
+	defer b.stats.track(StatsHistoryWalk)()
 	var err error
 
-	b.revs, err = references(b.fRev, b.path)
+	commits := []*object.Commit{b.fRev}
+	if len(b.tips) != 0 {
+		commits = make([]*object.Commit, len(b.tips))
+		named := false
+		for i, tip := range b.tips {
+			commits[i] = tip.Commit
+			named = named || tip.Ref != ""
+		}
+		if named {
+			if b.refs, err = reachableRefs(ctx, b.tips); err != nil {
+				return err
+			}
+		}
+	}
+	b.revs, err = references(ctx, commits, b.path)
+	if err != nil {
+		return err
+	}
+	if b.path != "" && len(b.revs) == 0 {
+		return &Error{Kind: ErrPathNotInHistory, Commit: b.fRev.Hash.String(), Path: b.path,
+			Err: object.ErrFileNotFound}
+	}
+	b.ignored, err = b.ignoredRevs()
 	return err
 }
 
 // build graph of a file from its revision history
-func (b *blame) fillGraphAndData() error {
+func (b *blame) fillGraphAndData(ctx context.Context) error {
+	defer helper.Duration(helper.Track("processing revisions"))
+	helper.Info("processing revisions", "revisions", len(b.revs), "path", b.path)
+	p := newProgress(ctx, PhaseProcessing, len(b.revs))
+	defer b.stats.track(StatsProcessing)()
 	//TODO: not all commits are needed, only the current rev and the prev
 	//b.graph = make([][]*object.Commit, len(b.revs))
 	b.graph = make(map[string][][]*object.Commit)
@@ -222,11 +404,18 @@ func (b *blame) fillGraphAndData() error {
 	for i, rev := range b.revs {
 		b.commitIndexMap[rev.Hash.String()] = i
 	}
+	// the lines of a revision are needed until all its children are
+	// processed, which may be long after it when several tips are walked
+	children := make([]int, len(b.revs))
+	for _, rev := range b.revs {
+		for _, p := range b.parentIndexes(rev) {
+			children[p]++
+		}
+	}
 
-	var opFileName = "outputs/output_" + time.Now().UTC().Format("2006-01-02T15:04:05-0700") + ".json"
 	// for every revision of the file, starting with the first
 	// one...
-	helper.AppendToFile(opFileName, "[")
+	b.appendOutput("[")
 	for i, rev := range b.revs {
 		//cTree, _ := rev.Tree()
 		//if rev.Hash.String() == "e15b720263903680264fdfb124749b6f386d51e6" {
@@ -239,31 +428,61 @@ func (b *blame) fillGraphAndData() error {
 		if b.lastCommitId != "" && b.lastCommitId == rev.Hash.String() {
 			break
 		}
+		if err := ctx.Err(); err != nil {
+			return b.abort(i, err)
+		}
 
-		ittr, _ := rev.Files()
+		// the nearest parent is the same for all the files of the revision
+		parent, isMerge := -1, false
+		if i != 0 {
+			parent, isMerge = b.nearestParent(rev)
+		}
+
+		changes, err := b.treeChanges(ctx, i, parent)
+		if err != nil {
+			return b.abort(i, wrapError(err, rev.Hash.String(), ""))
+		}
+		renamed := renames(changes)
+		var aliased []string
+
+		ittr, err := rev.Files()
+		if err != nil {
+			return b.abort(i, wrapError(err, rev.Hash.String(), ""))
+		}
 		commitFiles := make([]ChurnFile, 0)
+		seen := make(map[string]struct{})
+		lines := make(map[string]lineCounts)
+		var diffs []fileDiff
 		for {
 			file, err := ittr.Next()
 
-			if file == nil {
+			if err == io.EOF {
 				break
 			}
+			if err != nil {
+				return b.abort(i, wrapError(err, rev.Hash.String(), ""))
+			}
 			if (b.path != "" && b.path == file.Name) || (b.path == "") {
+				if err := ctx.Err(); err != nil {
+					return b.abort(i, err)
+				}
+				seen[file.Name] = struct{}{}
+				p.addFiles(1)
 				churnDetails := new(ChurnFile)
 				churnDetails.FileName = file.Name
 				// get the contents of the file
 				//file, err := rev.Filele(b.path)
-				if err != nil {
-					return nil
-				}
 				if _, ok := b.data[file.Name]; !ok {
 					//do something here
 					b.data[file.Name] = make([]string, len(b.revs))
 				}
+				stop := b.stats.track(StatsBlobReads)
 				b.data[file.Name][i], err = file.Contents()
+				stop()
 				if err != nil {
-					return err
+					return b.abort(i, wrapError(err, rev.Hash.String(), file.Name))
 				}
+				b.stats.count(0, 1, 1, 0)
 				nLines := countLines(b.data[file.Name][i])
 				// create a node for each line
 				if _, ok := b.graph[file.Name]; !ok {
@@ -274,83 +493,275 @@ func (b *blame) fillGraphAndData() error {
 				// assign a commit to each node
 				// if this is the first revision, then the node is assigned to
 				// this first commit.
-				if i == 0 {
+				if parent == -1 {
 					for j := 0; j < nLines; j++ {
 						b.graph[file.Name][i][j] = b.revs[i]
 					}
+					lines[file.Name] = lineCounts{insertions: nLines}
 				} else {
-					// if this is not the first commit, then assign to the old
-					// commit or to the new one, depending on what the diff
-					// says.
-
-					//if strings.Contains(rev.Message, "Merge pull request"){
-					//	continue
-					//}
-					//Setting it to MAX=1
-					nearestParent := len(b.revs) + 1
-					iter := rev.Parents()
-					count := 0
-					for {
-						parent, _ := iter.Next()
-						if parent == nil {
-							break
-						}
-						count++
-						//if count > 1 && strings.Contains(parent.Message, "Merge pull request") {
-						//	break
-						//}
-						//if count > 1 {
-						//	break
-						//}
-						parentIndex := b.commitIndexMap[parent.Hash.String()]
-						if nearestParent > parentIndex {
-							if count > 1 {
-								if !strings.Contains(parent.Message, "Merge pull request") {
-									nearestParent = parentIndex
-								}
-							} else {
-								nearestParent = parentIndex
-							}
-						} else if !strings.Contains(parent.Message, "Merge pull request") {
-							nearestParent = parentIndex
-						}
-						//if nearestParent > parentIndex {
-						//	nearestParent = parentIndex
-						//}
-					}
-					if count == 1 {
-						b.assignOrigin(i, nearestParent, churnDetails, false)
-					} else {
-						b.assignOrigin(i, nearestParent, churnDetails, true)
+					// a renamed file is diffed against the lines of its old
+					// name, which is not deleted
+					if from, ok := renamed[file.Name]; ok && b.graph[file.Name][parent] == nil &&
+						b.graph[from] != nil && b.graph[from][parent] != nil {
+						b.graph[file.Name][parent] = b.graph[from][parent]
+						b.data[file.Name][parent] = b.data[from][parent]
+						aliased = append(aliased, file.Name)
+						seen[from] = struct{}{}
 					}
+					diffs = append(diffs, fileDiff{churn: churnDetails, hunks: b.diff(i, parent, file.Name)})
 				}
-				if len(churnDetails.InteractiveChurn) != 0 || len(churnDetails.SelfChurn) != 0 {
-					commitFiles = append(commitFiles, *churnDetails)
-				}
 			}
 		}
+		// lines can be moved across files, so the origins are assigned once
+		// all the files are diffed
+		moves := b.findMoves(i, parent, diffs, seen)
+		if b.skip != nil && parent != -1 && b.skip(rev, diffs) {
+			if b.ignored == nil {
+				b.ignored = make(map[int]bool)
+			}
+			b.ignored[i] = true
+		}
+		for _, d := range diffs {
+			if b.onDiff != nil && !b.ignored[i] {
+				b.onDiff(d.churn.FileName, d.hunks, b.graph[d.churn.FileName][parent], rev)
+			}
+			// if this is not the first commit, then assign to the old
+			// commit or to the new one, depending on what the diff
+			// says.
+			var merged []*object.Commit
+			if isMerge {
+				merged = b.mergedOrigins(i, rev, parent, d.churn.FileName)
+			}
+			lines[d.churn.FileName] = b.assignOrigin(i, parent, d.churn, d.hunks, moves, merged)
+			if len(d.churn.InteractiveChurn) != 0 || len(d.churn.SelfChurn) != 0 || len(d.churn.Moved) != 0 {
+				commitFiles = append(commitFiles, *d.churn)
+			}
+		}
+		// a merge deletes nothing, its lines come from one parent or another
+		if parent != -1 && !isMerge {
+			b.deleteFiles(i, parent, seen, moves)
+		}
+		for _, name := range aliased {
+			b.graph[name][parent] = nil
+			b.data[name][parent] = ""
+		}
+		b.release(rev, children)
+		stats, err := b.commitStats(i, changes, lines, commitFiles)
+		if err != nil {
+			return b.abort(i, wrapError(err, rev.Hash.String(), ""))
+		}
 		//if len(commitFiles) != 0 {
 		//b.ChurnFiles[i] = commitFiles
+		issues, pullRequests := ExtractIssues(IssueExtractors, b.revs[i].Message)
 		churn := Churn{
 			CommitID:      b.revs[i].Hash.String(),
 			CommitAuthor:  b.revs[i].Author.Email,
 			Date:          b.revs[i].Author.When.String(),
 			CommitMessage: b.revs[i].Message,
 			ChurnFiles:    commitFiles,
+			Refs:          b.refs[b.revs[i].Hash],
+			Parents:       parentHashes(rev),
+			Stats:         stats,
+			Ignored:       b.ignored[i],
+			Issues:        issues,
+			PullRequests:  pullRequests,
 		}
+		if b.onChurn != nil {
+			b.onChurn(churn)
+		}
+		p.report(i+1, "")
+		b.stats.count(1, 0, 0, 0)
+		stop := b.stats.track(StatsOutput)
 		data, _ := json.Marshal(churn)
 		if i != 0 {
-			helper.AppendToFile(opFileName, ",")
+			b.appendOutput(",")
 		}
-		helper.AppendToFile(opFileName, string(data)+"\n")
+		b.appendOutput(string(data) + "\n")
+		stop()
 		//fmt.Printf("%s\n", data)
 		//fmt.Println("\n")
 		//}
 	}
-	helper.AppendToFile(opFileName, "]")
+	b.appendOutput("]")
 	return nil
 }
 
+// Interruption is the last record of the output of a run interrupted, or
+// failed, before all the revisions were processed.
+type Interruption struct {
+	Incomplete bool
+	// LastCommitID is the last commit whose churn was fully processed and
+	// written, empty if there is none.
+	LastCommitID string
+	Reason       string
+}
+
+// InterruptedError is returned when the processing of the revisions is
+// interrupted because its context is done.
+type InterruptedError struct {
+	// LastCommitID is the last commit whose churn was fully processed.
+	LastCommitID string
+	// OutputFile is the file the partial result was written to, if any.
+	OutputFile string
+	Err        error
+}
+
+func (e *InterruptedError) Error() string {
+	if e.LastCommitID == "" {
+		return "interrupted before processing any commit: " + e.Err.Error()
+	}
+	return "interrupted after commit " + e.LastCommitID + ": " + e.Err.Error()
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// Is tells that an InterruptedError is of kind ErrCancelled.
+func (e *InterruptedError) Is(target error) bool {
+	return target == ErrCancelled
+}
+
+// abort closes the output with an Interruption record when the revision i
+// could not be processed because of err. It returns an *InterruptedError if
+// the context is done, and err otherwise.
+func (b *blame) abort(i int, err error) error {
+	interruption := Interruption{
+		Incomplete: true,
+		Reason:     err.Error(),
+	}
+	if i != 0 {
+		interruption.LastCommitID = b.revs[i-1].Hash.String()
+		b.appendOutput(",")
+	}
+	data, _ := json.Marshal(interruption)
+	b.appendOutput(string(data) + "\n")
+	b.appendOutput("]")
+	if errorKind(err) != ErrCancelled {
+		return err
+	}
+	return &InterruptedError{
+		LastCommitID: interruption.LastCommitID,
+		OutputFile:   b.opFileName,
+		Err:          err,
+	}
+}
+
+// appendOutput appends text to the output file, if there is one.
+func (b *blame) appendOutput(text string) {
+	if b.opFileName == "" {
+		return
+	}
+	helper.AppendToFile(b.opFileName, text)
+}
+
+// nearestParent returns the index in b.revs of the parent of rev whose
+// contents are compared against rev, or -1 if rev has no parents. Parents that are merge pull requests are avoided unless they are
+// the first parent. isMerge tells if rev has more than one parent.
+func (b *blame) nearestParent(rev *object.Commit) (nearest int, isMerge bool) {
+	//Setting it to MAX=1
+	nearestParent := len(b.revs) + 1
+	iter := rev.Parents()
+	count := 0
+	for {
+		parent, _ := iter.Next()
+		if parent == nil {
+			break
+		}
+		count++
+		parentIndex, ok := b.walkedAncestor(parent)
+		if !ok {
+			continue
+		}
+		if nearestParent > parentIndex {
+			if count > 1 {
+				if !strings.Contains(parent.Message, "Merge pull request") {
+					nearestParent = parentIndex
+				}
+			} else {
+				nearestParent = parentIndex
+			}
+		} else if !strings.Contains(parent.Message, "Merge pull request") {
+			nearestParent = parentIndex
+		}
+	}
+	if nearestParent > len(b.revs) {
+		return -1, count > 1
+	}
+	return nearestParent, count > 1
+}
+
+// walkedAncestor returns the index in b.revs of c or, when only the revisions
+// changing a path are walked, of its nearest first-parent ancestor in b.revs.
+func (b *blame) walkedAncestor(c *object.Commit) (int, bool) {
+	for {
+		if i, ok := b.commitIndexMap[c.Hash.String()]; ok {
+			return i, true
+		}
+		if b.path == "" || c.NumParents() == 0 {
+			return -1, false
+		}
+		var err error
+		if c, err = c.Parent(0); err != nil {
+			return -1, false
+		}
+	}
+}
+
+// parentIndexes returns the indices in b.revs of the parents of rev, or of
+// their walked ancestors.
+func (b *blame) parentIndexes(rev *object.Commit) []int {
+	var result []int
+	iter := rev.Parents()
+	defer iter.Close()
+	for {
+		parent, _ := iter.Next()
+		if parent == nil {
+			return result
+		}
+		if p, ok := b.walkedAncestor(parent); ok {
+			result = append(result, p)
+		}
+	}
+}
+
+// release forgets the lines and the contents of the parents of rev whose
+// children are all processed, given the number of children left of every
+// revision.
+func (b *blame) release(rev *object.Commit, children []int) {
+	for _, p := range b.parentIndexes(rev) {
+		children[p]--
+		if children[p] != 0 {
+			continue
+		}
+		for name, revs := range b.graph {
+			revs[p] = nil
+			b.data[name][p] = ""
+		}
+	}
+}
+
+// deleteFiles reports as deleted by revision c all the lines of the files
+// that are in the revision p but not in c, but the moved ones.
+func (b *blame) deleteFiles(c, p int, seen map[string]struct{}, moves *moveSet) {
+	if b.onDelete == nil || b.ignored[c] {
+		return
+	}
+	for name, revs := range b.graph {
+		if _, ok := seen[name]; ok || revs[p] == nil {
+			continue
+		}
+		if b.path != "" && b.path != name {
+			continue
+		}
+		for n, origin := range revs[p] {
+			if !moves.isMoved(name, n) {
+				b.onDelete(name, origin, b.revs[c])
+			}
+		}
+	}
+}
+
 // sliceGraph returns a slice of commits (one per line) for a particular
 // revision of a file (0=first revision).
 //func (b *blame) sliceGraph(i int) []*object.Commit {
@@ -363,56 +774,115 @@ func (b *blame) fillGraphAndData() error {
 //	return result
 //}
 
-// Assigns origin to vertexes in current (c) rev from data in its previous (p)
-// revision
-func (b *blame) assignOrigin(c, p int, churnDetails *ChurnFile, copyAsIs bool) {
-	// assign origin based on diff info
-	hunks := diff.Do(b.data[churnDetails.FileName][p], b.data[churnDetails.FileName][c])
+// diff returns the diff of the file name between the revisions p and c.
+func (b *blame) diff(c, p int, name string) []linediff.Hunk {
+	src, dst := b.data[name][p], b.data[name][c]
+	defer b.stats.track(StatsDiffs)()
+	b.stats.count(0, 0, 0, int64(len(src)+len(dst)))
+	return DiffAlgorithm.Do(src, dst)
+}
+
+// mergedOrigins returns the origins of the lines of the file name in the
+// merge revision c, rev, that come from its parents other than p, nil for the
+// lines that are in none of them.
+func (b *blame) mergedOrigins(c int, rev *object.Commit, p int, name string) []*object.Commit {
+	result := make([]*object.Commit, len(b.graph[name][c]))
+	for _, q := range b.parentIndexes(rev) {
+		if q == p || b.graph[name][q] == nil {
+			continue
+		}
+		sl, dl := -1, -1
+		for _, hunk := range b.diff(c, q, name) {
+			for range hunk.Lines {
+				switch hunk.Op {
+				case linediff.Equal:
+					sl++
+					dl++
+					if result[dl] == nil {
+						result[dl] = b.graph[name][q][sl]
+					}
+				case linediff.Insert:
+					dl++
+				case linediff.Delete:
+					sl++
+				}
+			}
+		}
+	}
+	return result
+}
 
+// Assigns origin to vertexes in current (c) rev from data in its previous (p)
+// revision, given the hunks of their diff and the lines moved or copied, and
+// returns the number of lines inserted and deleted. For a merge, merged are
+// the origins of the lines from its other parents: the lines of no parent are
+// assigned to c and the deleted ones are not churn.
+func (b *blame) assignOrigin(c, p int, churnDetails *ChurnFile, hunks []linediff.Hunk, moves *moveSet,
+	merged []*object.Commit) lineCounts {
+	var counts lineCounts
+	if b.ignored[c] {
+		b.ignoreOrigins(c, p, churnDetails.FileName, hunks, moves)
+		for _, hunk := range hunks {
+			switch hunk.Op {
+			case linediff.Insert:
+				counts.insertions += len(hunk.Lines)
+			case linediff.Delete:
+				counts.deletions += len(hunk.Lines)
+			}
+		}
+		return counts
+	}
 	sl := -1 // source line
 	dl := -1 // destination line
 	for h := range hunks {
-		hLines := countLines(hunks[h].Text)
+		hLines := len(hunks[h].Lines)
 		for hl := 0; hl < hLines; hl++ {
 			switch {
-			case hunks[h].Type == 0:
+			case hunks[h].Op == linediff.Equal:
 				sl++
 				dl++
 				b.graph[churnDetails.FileName][c][dl] = b.graph[churnDetails.FileName][p][sl]
-			case hunks[h].Type == 1:
+			case hunks[h].Op == linediff.Insert:
 				dl++
-				if copyAsIs {
-					//if strings.Contains(b.revs[p].Message, "Merge pull request") {
-					//	fmt.Println(b.revs[c].Hash.String())
-					//}
-					b.graph[churnDetails.FileName][c][dl] = b.revs[p]
+				counts.insertions++
+				if merged != nil && merged[dl] != nil {
+					b.graph[churnDetails.FileName][c][dl] = merged[dl]
+				} else if from, ok := moves.origin(churnDetails.FileName, dl); ok && merged == nil {
+					b.graph[churnDetails.FileName][c][dl] = b.graph[from.file][p][from.n]
 				} else {
 					//if strings.Contains(b.revs[c].Message, "Merge pull request") {
 					//	fmt.Println(b.revs[c].Hash.String())
 					//}
 					b.graph[churnDetails.FileName][c][dl] = b.revs[c]
 				}
-			case hunks[h].Type == -1:
+			case hunks[h].Op == linediff.Delete:
 				sl++
+				counts.deletions++
+				if merged != nil {
+					continue
+				}
+				if moves.isMoved(churnDetails.FileName, sl) {
+					churnDetails.Moved = append(churnDetails.Moved, sl+1)
+					continue
+				}
+				if b.onDelete != nil {
+					b.onDelete(churnDetails.FileName, b.graph[churnDetails.FileName][p][sl], b.revs[c])
+				}
 				if b.revs[c].Author.Email == b.graph[churnDetails.FileName][p][sl].Author.Email {
 					churnDetails.SelfChurn = append(churnDetails.SelfChurn, sl+1)
 				} else {
-					ichurn := churnDetails.InteractiveChurn[b.graph[churnDetails.FileName][p][sl].Author.Email]
-					if len(ichurn) == 0 {
+					if churnDetails.InteractiveChurn == nil {
 						churnDetails.InteractiveChurn = make(map[string][]int)
 					}
-					ichurn = append(ichurn, sl+1)
-					churnDetails.InteractiveChurn[b.graph[churnDetails.FileName][p][sl].Author.Email] = ichurn
+					author := b.graph[churnDetails.FileName][p][sl].Author.Email
+					churnDetails.InteractiveChurn[author] = append(churnDetails.InteractiveChurn[author], sl+1)
 				}
 			default:
 				panic("unreachable")
 			}
 		}
 	}
-	if c-20 > 0 {
-		b.graph[churnDetails.FileName][c-20] = make([]*object.Commit, 0)
-		b.data[churnDetails.FileName][c-20] = ""
-	}
+	return counts
 }
 
 // GoString prints the results of a Blame using git-blame's style.
@@ -0,0 +1,78 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// lines returns the lines "prefix1" to "prefixN".
+func lines(prefix string, n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%s%d\n", prefix, i)
+	}
+	return b.String()
+}
+
+// A branch commit whose parent is many revisions older than it, as the
+// revisions of several tips are interleaved by date, is still diffed against
+// the lines of its parent.
+func TestChurnOfOldParent(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("alice", map[string]string{"README": "readme\n"})
+	tr.commit("alice", map[string]string{"f.txt": lines("a", 10)})
+	tr.checkout("feature", true)
+	tr.checkout("master", false)
+	master := tr.commit("bob", map[string]string{"g.txt": lines("b", 1)})
+	for i := 2; i <= 30; i++ {
+		master = tr.commit("bob", map[string]string{"g.txt": lines("b", i)})
+	}
+	tr.checkout("feature", false)
+	feature := tr.commit("carol", map[string]string{"f.txt": strings.Replace(lines("a", 10), "a5\n", "c5\n", 1)})
+
+	churns, err := metrics.Churns(context.Background(), feature, &metrics.ChurnOptions{
+		Tips: []metrics.Tip{{Commit: master, Ref: "master"}, {Commit: feature, Ref: "feature"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := churns[len(churns)-1]
+	if last.CommitID != feature.Hash.String() {
+		t.Fatalf("last churn of %s, want %s", last.CommitID, feature.Hash)
+	}
+	if last.Stats.Insertions != 1 || last.Stats.Deletions != 1 || last.Stats.InteractiveChurn != 1 {
+		t.Errorf("stats %+v, want 1 insertion, 1 deletion and 1 line of interactive churn", last.Stats)
+	}
+	want := []metrics.ChurnFile{{FileName: "f.txt", InteractiveChurn: map[string][]int{"alice": {5}}}}
+	if !reflect.DeepEqual(last.ChurnFiles, want) {
+		t.Errorf("churn files %+v, want %+v", last.ChurnFiles, want)
+	}
+}
+
+// When the churn of a path is computed, a commit whose parent does not change
+// the path is diffed against the last commit that does.
+func TestChurnOfPath(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("alice", map[string]string{"f.txt": "a1\na2\na3\n"})
+	tr.commit("bob", map[string]string{"f.txt": "a1\na2\nb3\n"})
+	tr.commit("bob", map[string]string{"g.txt": "b1\n"})
+	head := tr.commit("carol", map[string]string{"f.txt": "c1\na2\nb3\n"})
+
+	churns, err := metrics.Churns(context.Background(), head, &metrics.ChurnOptions{Path: "f.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := churns[len(churns)-1]
+	if last.Stats.Insertions != 1 || last.Stats.Deletions != 1 {
+		t.Errorf("stats %+v, want 1 insertion and 1 deletion", last.Stats)
+	}
+	want := []metrics.ChurnFile{{FileName: "f.txt", InteractiveChurn: map[string][]int{"alice": {1}}}}
+	if !reflect.DeepEqual(last.ChurnFiles, want) {
+		t.Errorf("churn files %+v, want %+v", last.ChurnFiles, want)
+	}
+}
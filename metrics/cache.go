@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// CacheDir, if not empty, is the directory remote repositories are cloned
+// to. A repository is cloned once, the following runs only fetch the new
+// objects.
+var CacheDir string
+
+// cacheRefSpecs mirror the branches and the tags of the remote, and keep the
+// remote branches up to date.
+var cacheRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/heads/*:refs/remotes/origin/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// CacheEntry describes a repository in the cache.
+type CacheEntry struct {
+	// URL is the URL the repository was first cloned from, without its
+	// credentials.
+	URL string
+	// Key is the normalized URL the repository is cached under.
+	Key string
+	// Dir is the directory the repository is in.
+	Dir       string
+	Created   time.Time
+	LastFetch time.Time
+	// Size is the size in bytes of the repository on disk.
+	Size int64 `json:"-"`
+}
+
+// isRemote tells if repoUrl is not a path on the local filesystem.
+func isRemote(repoUrl string) bool {
+	ep, err := transport.NewEndpoint(repoUrl)
+	return err == nil && ep.Protocol != "file"
+}
+
+// NormalizeURL returns the key a repository is cached under: the protocol,
+// host and path of its URL, without credentials, case-insensitive host and
+// without the ".git" suffix.
+func NormalizeURL(repoUrl string) (string, error) {
+	ep, err := transport.NewEndpoint(repoUrl)
+	if err != nil {
+		return "", err
+	}
+	host := strings.ToLower(ep.Host)
+	if ep.Port != 0 {
+		host = fmt.Sprintf("%s:%d", host, ep.Port)
+	}
+	path := strings.Trim(ep.Path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	return ep.Protocol + "://" + host + "/" + path, nil
+}
+
+// stripCredentials returns repoUrl without the credentials of its user info,
+// so that they are not stored with the repository. Only the user name of an
+// SSH URL is kept, as it is needed to connect.
+func stripCredentials(repoUrl string) string {
+	u, err := url.Parse(repoUrl)
+	if err != nil || u.User == nil || !strings.Contains(repoUrl, "://") {
+		return repoUrl
+	}
+	if u.Scheme == "ssh" {
+		u.User = url.User(u.User.Username())
+	} else {
+		u.User = nil
+	}
+	return u.String()
+}
+
+// cacheDirName returns the directory name of the repository cached under key,
+// a readable name followed by a hash of the key.
+func cacheDirName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := key[strings.Index(key, "://")+3:]
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == ':' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return name + "-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// openCached returns the repository at repoUrl from the cache, cloning it if
+// it is not there or its metadata is corrupt, and fetching the new objects
+// otherwise. The processes sharing the cache clone and fetch a repository
+// one at a time.
+func openCached(ctx context.Context, repoUrl string) (*git.Repository, *CacheEntry, error) {
+	key, err := NormalizeURL(repoUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts, err := cloneOptions(repoUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the credentials of the URL are in opts.Auth
+	opts.URL = stripCredentials(repoUrl)
+	name := cacheDirName(key)
+	entry := &CacheEntry{
+		URL: opts.URL,
+		Key: key,
+		Dir: filepath.Join(CacheDir, name),
+	}
+	metaFile := entry.Dir + ".json"
+	opts.Progress = newProgress(ctx, PhaseCloning, 0).writer()
+
+	if err := os.MkdirAll(CacheDir, 0755); err != nil {
+		return nil, nil, err
+	}
+	unlock, err := lockFile(entry.Dir + ".lock")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	r, err := git.PlainOpen(entry.Dir)
+	if err == nil && !entry.readMeta(metaFile) {
+		// a corrupt entry is a miss
+		if err := os.RemoveAll(entry.Dir); err != nil {
+			return nil, nil, err
+		}
+		err = git.ErrRepositoryNotExists
+	} else if err == nil {
+		// the entries of older versions kept the credentials
+		entry.URL = stripCredentials(entry.URL)
+		err = setRemoteURL(r, opts.URL)
+	}
+	if err == git.ErrRepositoryNotExists {
+		r, err = git.PlainCloneContext(ctx, entry.Dir, true, opts)
+		if err != nil {
+			os.RemoveAll(entry.Dir)
+			return nil, nil, err
+		}
+		entry.Created = time.Now()
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	err = r.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   cacheRefSpecs,
+		Auth:       opts.Auth,
+		Tags:       git.AllTags,
+		Force:      true,
+		Progress:   opts.Progress,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, nil, err
+	}
+
+	entry.LastFetch = time.Now()
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ioutil.WriteFile(metaFile, data, 0644); err != nil {
+		return nil, nil, err
+	}
+	return r, entry, nil
+}
+
+// setRemoteURL sets the URL of the origin remote of r, if it is not the same.
+func setRemoteURL(r *git.Repository, repoUrl string) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	remote, ok := cfg.Remotes[git.DefaultRemoteName]
+	if !ok || len(remote.URLs) == 1 && remote.URLs[0] == repoUrl {
+		return nil
+	}
+	remote.URLs = []string{repoUrl}
+	return r.Storer.SetConfig(cfg)
+}
+
+// readMeta reads the metadata of the entry from the file name, if it exists,
+// and tells if it could.
+func (e *CacheEntry) readMeta(name string) bool {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return true
+	} else if err != nil {
+		return false
+	}
+	cached := *e
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return false
+	}
+	cached.Dir = e.Dir
+	*e = cached
+	return true
+}
+
+// ListCache returns the repositories in the cache directory dir, sorted by
+// the date of their last fetch, the oldest first.
+func ListCache(dir string) ([]CacheEntry, error) {
+	metaFiles, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CacheEntry, 0, len(metaFiles))
+	for _, metaFile := range metaFiles {
+		data, err := ioutil.ReadFile(metaFile)
+		if err != nil {
+			return nil, err
+		}
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("%s: %v", metaFile, err)
+		}
+		entry.Dir = strings.TrimSuffix(metaFile, ".json")
+		entry.Size, err = dirSize(entry.Dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastFetch.Before(result[j].LastFetch)
+	})
+	return result, nil
+}
+
+// PruneCache removes from the cache directory dir the repositories that were
+// not fetched for longer than olderThan, and returns them.
+func PruneCache(dir string, olderThan time.Duration) ([]CacheEntry, error) {
+	entries, err := ListCache(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []CacheEntry
+	for _, entry := range entries {
+		if time.Since(entry.LastFetch) < olderThan {
+			continue
+		}
+		if err := removeCached(entry.Dir); err != nil {
+			return result, err
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// removeCached removes the repository of the cache in dir and its metadata,
+// waiting for a clone or fetch of it in progress.
+func removeCached(dir string) error {
+	unlock, err := lockFile(dir + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Remove(dir + ".json")
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// withCacheDir sets metrics.CacheDir to a new directory for the test.
+func withCacheDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := metrics.CacheDir
+	metrics.CacheDir = dir
+	t.Cleanup(func() {
+		metrics.CacheDir = saved
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// The processes sharing the cache clone a repository once, and a corrupt
+// metadata file is a miss.
+func TestCache(t *testing.T) {
+	s := httpBackend(t, remoteRepo(t), "git", "secret")
+	url := s.URL + "/repo/.git"
+	withAuth(t, metrics.AuthOptions{Token: "secret"})
+	dir := withCacheDir(t)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = metrics.OpenRepo(context.Background(), url)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("open %d: %v", i, err)
+		}
+	}
+	entries, err := metrics.ListCache(dir)
+	if err != nil || len(entries) != 1 || entries[0].URL != url {
+		t.Fatalf("cache %+v, %v, want %s", entries, err, url)
+	}
+	created := entries[0].Created
+
+	if err := ioutil.WriteFile(entries[0].Dir+".json", []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := metrics.OpenRepo(context.Background(), url); err != nil {
+		t.Fatalf("open with corrupt metadata: %v", err)
+	}
+	entries, err = metrics.ListCache(dir)
+	if err != nil || len(entries) != 1 || !entries[0].Created.After(created) {
+		t.Fatalf("cache %+v, %v, want the repository cloned again", entries, err)
+	}
+
+	pruned, err := metrics.PruneCache(dir, 0)
+	if err != nil || len(pruned) != 1 {
+		t.Fatalf("pruned %+v, %v, want 1 repository", pruned, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.Base(pruned[0].Dir))); !os.IsNotExist(err) {
+		t.Errorf("%s not removed: %v", pruned[0].Dir, err)
+	}
+}
+
+// The credentials of a URL are used but not stored.
+func TestCacheCredentials(t *testing.T) {
+	s := httpBackend(t, remoteRepo(t), "git", "secret")
+	url := strings.Replace(s.URL, "://", "://git:secret@", 1) + "/repo/.git"
+	withAuth(t, metrics.AuthOptions{})
+	dir := withCacheDir(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := metrics.OpenRepo(context.Background(), url); err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+	}
+	entries, err := metrics.ListCache(dir)
+	if err != nil || len(entries) != 1 || entries[0].URL != s.URL+"/repo/.git" {
+		t.Fatalf("cache %+v, %v, want %s", entries, err, s.URL+"/repo/.git")
+	}
+	for _, name := range []string{entries[0].Dir + ".json", filepath.Join(entries[0].Dir, "config")} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("%s holds the password:\n%s", filepath.Base(name), data)
+		}
+	}
+}
@@ -1,80 +1,192 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
+	"github.com/ashishgalagali/go-git-churn/helper"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/go-git/go-git/v5"
-	"os"
+	"strings"
 	//"time"
 )
 
-func Checkout(repoUrl, hash string) *git.Repository {
+func Checkout(repoUrl, hash string) (*git.Repository, error) {
 	//PrintInBlue("git clone " + repoUrl)
 
-	r := GetRepo(repoUrl)
+	r, err := GetRepo(repoUrl)
+	if err != nil {
+		return nil, err
+	}
 	w, err := r.Worktree()
-	CheckIfError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	// ... checking out to commit
 	//PrintInBlue("git checkout %s", hash)
 	err = w.Checkout(&git.CheckoutOptions{
 		Hash: plumbing.NewHash(hash),
 	})
-	CheckIfError(err)
-	return r
+	if err != nil {
+		return nil, wrapError(err, hash, "")
+	}
+	return r, nil
 }
 
-func GetRepo(repoUrl string) *git.Repository {
-	//defer helper.Duration(helper.Track("GetRepo"))
+func GetRepo(repoUrl string) (*git.Repository, error) {
+	defer helper.Duration(helper.Track("GetRepo"))
 
 	//PrintInBlue("git clone " + repoUrl)
 
 	var r *git.Repository
 	var err error
 	//if strings.HasPrefix(repoUrl, "https://github.com") {
-	r, err = git.Clone(memory.NewStorage(), memfs.New(), &git.CloneOptions{
-		URL: repoUrl,
-	})
-	CheckIfError(err)
+	opts, err := cloneOptions(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	if CacheDir != "" && isRemote(repoUrl) {
+		// the worktree is checked out from the cached clone
+		_, entry, err := openCached(context.Background(), repoUrl)
+		if err != nil {
+			return nil, repoError(repoUrl, err)
+		}
+		opts = &git.CloneOptions{URL: entry.Dir}
+	}
+	r, err = git.Clone(memory.NewStorage(), memfs.New(), opts)
+	if err != nil {
+		return nil, repoError(repoUrl, err)
+	}
 	//} else {
 	//	r, err = git.PlainOpen(repoUrl)
 	//	CheckIfError(err)
 	//}
-	return r
+	return r, nil
 }
 
-func LastCommit(repoUrl string) *object.Commit {
+func LastCommit(ctx context.Context, repoUrl string) (*object.Commit, error) {
 	// Clones the given repository in memory, creating the remote, the local
 	// branches and fetching the objects, exactly as:
 	//PrintInBlue("git clone " + repoUrl)
 
-	r, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
-		URL: repoUrl,
-	})
-
-	CheckIfError(err)
+	r, err := OpenRepo(ctx, repoUrl)
+	if err != nil {
+		return nil, err
+	}
 
 	// ... retrieving the branch being pointed by HEAD
-	ref, err := r.Head()
-	CheckIfError(err)
 	// ... retrieving the commit object
-	commit, err := r.CommitObject(ref.Hash())
-	CheckIfError(err)
+	return resolveCommit(r, "HEAD")
+}
+
+// CommitAt clones the given repository in memory and returns the commit the
+// revision rev resolves to. An empty rev resolves to HEAD.
+func CommitAt(ctx context.Context, repoUrl, rev string) (*object.Commit, error) {
+	r, err := OpenRepo(ctx, repoUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return resolveCommit(r, rev)
+}
+
+// CommitRange clones the given repository in memory and resolves a revision
+// range "from..to". from is nil if the range has no lower bound, an empty to
+// resolves to HEAD.
+func CommitRange(ctx context.Context, repoUrl, revRange string) (from, to *object.Commit, err error) {
+	r, err := OpenRepo(ctx, repoUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ResolveRange(r, revRange)
+}
 
-	//fmt.Println(commit)
+// OpenRepo clones the given repository in memory, with the credentials in
+// Auth. If CacheDir is set remote repositories are opened from the cache.
+func OpenRepo(ctx context.Context, repoUrl string) (*git.Repository, error) {
+	defer helper.Duration(helper.Track("clone"))
+	defer statsFrom(ctx).track(StatsClone)()
+	helper.Info("cloning", "repo", repoUrl)
+	if CacheDir != "" && isRemote(repoUrl) {
+		r, _, err := openCached(ctx, repoUrl)
+		return r, repoError(repoUrl, err)
+	}
+	opts, err := cloneOptions(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	opts.Progress = newProgress(ctx, PhaseCloning, 0).writer()
+	r, err := git.CloneContext(ctx, memory.NewStorage(), nil, opts)
+	return r, repoError(repoUrl, err)
+}
 
-	return commit
+// FetchRepo fetches the new commits of the branches and the tags of the
+// repository at repoUrl into r, opened from it by OpenRepo, so that its
+// branches are up to date.
+func FetchRepo(ctx context.Context, r *git.Repository, repoUrl string) error {
+	opts, err := cloneOptions(repoUrl)
+	if err != nil {
+		return err
+	}
+	err = r.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   cacheRefSpecs,
+		Auth:       opts.Auth,
+		Tags:       git.AllTags,
+		Force:      true,
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return repoError(repoUrl, err)
 }
 
-// CheckIfError should be used to naively panics if an error is not nil.
-func CheckIfError(err error) {
+// repoError returns the error err that happened while opening the repository
+// at repoUrl, if any.
+func repoError(repoUrl string, err error) error {
 	if err == nil {
-		return
+		return nil
 	}
+	return &Error{Kind: errorKind(err), Err: fmt.Errorf("%s: %w", repoUrl, err)}
+}
 
-	fmt.Printf("\x1b[31;1m%s\x1b[0m\n", fmt.Sprintf("error: %s", err))
-	os.Exit(1)
+// ResolveRange resolves a revision range "from..to" in r. from is nil if the
+// range has no lower bound, an empty to resolves to HEAD.
+func ResolveRange(r *git.Repository, revRange string) (from, to *object.Commit, err error) {
+	fromRev, toRev := "", revRange
+	if i := strings.Index(revRange, ".."); i != -1 {
+		fromRev, toRev = revRange[:i], revRange[i+2:]
+	}
+	if toRev == "" {
+		toRev = "HEAD"
+	}
+	if to, err = resolveCommit(r, toRev); err != nil {
+		return nil, nil, err
+	}
+	if fromRev != "" {
+		if from, err = resolveCommit(r, fromRev); err != nil {
+			return nil, nil, err
+		}
+	}
+	return from, to, nil
+}
+
+// resolveCommit returns the commit rev resolves to, or an error of kind
+// ErrRevisionNotFound.
+func resolveCommit(r *git.Repository, rev string) (*object.Commit, error) {
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, &Error{Kind: ErrRevisionNotFound, Commit: rev, Err: err}
+	}
+	c, err := r.CommitObject(*h)
+	if err != nil {
+		return nil, &Error{Kind: ErrRevisionNotFound, Commit: rev, Err: err}
+	}
+	return c, nil
 }
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/ashishgalagali/go-git-churn/linediff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// CommitStats summarizes the changes of a commit against the parent its churn
+// is computed against.
+type CommitStats struct {
+	FilesChanged int
+	FilesAdded   int
+	FilesDeleted int
+	FilesRenamed int
+	Insertions   int
+	Deletions    int
+	// SelfChurn and InteractiveChurn are the number of lines deleted that
+	// were written by the author of the commit and by other authors.
+	SelfChurn        int
+	InteractiveChurn int
+	// TouchedAuthors is the number of distinct authors whose lines were
+	// deleted.
+	TouchedAuthors int
+	// Moved is the number of lines deleted that were moved within their
+	// file, they are counted in Deletions but not as churn.
+	Moved int
+}
+
+// lineCounts are the lines inserted and deleted in a file.
+type lineCounts struct {
+	insertions int
+	deletions  int
+}
+
+// commitStats returns the stats of the revision i given the changes of its
+// tree against the one of its parent. lines are the line counts of the files
+// of i already diffed against the parent, files its churn.
+func (b *blame) commitStats(i int, changes object.Changes, lines map[string]lineCounts,
+	files []ChurnFile) (CommitStats, error) {
+	var stats CommitStats
+	authors := make(map[string]struct{})
+	for _, f := range files {
+		stats.SelfChurn += len(f.SelfChurn)
+		stats.Moved += len(f.Moved)
+		if len(f.SelfChurn) != 0 {
+			authors[b.revs[i].Author.Email] = struct{}{}
+		}
+		for author, churn := range f.InteractiveChurn {
+			stats.InteractiveChurn += len(churn)
+			authors[author] = struct{}{}
+		}
+	}
+	stats.TouchedAuthors = len(authors)
+
+	for _, change := range changes {
+		if b.path != "" && change.From.Name != b.path && change.To.Name != b.path {
+			continue
+		}
+		action, err := change.Action()
+		if err != nil {
+			return stats, err
+		}
+		stats.FilesChanged++
+		switch {
+		case action == merkletrie.Insert:
+			stats.FilesAdded++
+			stats.Insertions += lines[change.To.Name].insertions
+		case action == merkletrie.Delete:
+			stats.FilesDeleted++
+			from, _, err := change.Files()
+			if err != nil {
+				return stats, err
+			}
+			n, err := from.Lines()
+			if err != nil {
+				return stats, err
+			}
+			stats.Deletions += len(n)
+		case change.From.Name == change.To.Name:
+			stats.Insertions += lines[change.To.Name].insertions
+			stats.Deletions += lines[change.To.Name].deletions
+		default:
+			stats.FilesRenamed++
+			from, to, err := change.Files()
+			if err != nil {
+				return stats, err
+			}
+			counts, err := diffFiles(from, to)
+			if err != nil {
+				return stats, err
+			}
+			stats.Insertions += counts.insertions
+			stats.Deletions += counts.deletions
+		}
+	}
+	return stats, nil
+}
+
+// treeChanges returns the changes of the tree of the revision i against the
+// one of the revision parent, -1 if it has none, renames included.
+func (b *blame) treeChanges(ctx context.Context, i, parent int) (object.Changes, error) {
+	tree, err := b.revs[i].Tree()
+	if err != nil {
+		return nil, err
+	}
+	parentTree := &object.Tree{}
+	if parent != -1 {
+		if parentTree, err = b.revs[parent].Tree(); err != nil {
+			return nil, err
+		}
+	}
+	return object.DiffTreeWithOptions(ctx, parentTree, tree, object.DefaultDiffTreeOptions)
+}
+
+// renames returns the old names of the files renamed by the changes, by new
+// name.
+func renames(changes object.Changes) map[string]string {
+	result := make(map[string]string)
+	for _, change := range changes {
+		if change.From.Name != "" && change.To.Name != "" && change.From.Name != change.To.Name {
+			result[change.To.Name] = change.From.Name
+		}
+	}
+	return result
+}
+
+// diffFiles returns the line counts of the diff between the files from and
+// to.
+func diffFiles(from, to *object.File) (lineCounts, error) {
+	var counts lineCounts
+	src, err := from.Contents()
+	if err != nil {
+		return counts, err
+	}
+	dst, err := to.Contents()
+	if err != nil {
+		return counts, err
+	}
+	for _, hunk := range DiffAlgorithm.Do(src, dst) {
+		switch hunk.Op {
+		case linediff.Insert:
+			counts.insertions += len(hunk.Lines)
+		case linediff.Delete:
+			counts.deletions += len(hunk.Lines)
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/ashishgalagali/go-git-churn/synth"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// linearSpec is synth.DefaultSpec without merges and renames.
+func linearSpec(seed int64) synth.Spec {
+	spec := synth.DefaultSpec
+	spec.Seed = seed
+	spec.MergeRatio, spec.RenameRatio = 0, 0
+	return spec
+}
+
+// churnsOf builds the repository of spec and returns what synth expects and
+// the churn of every commit computed by the tool, by commit id.
+func churnsOf(t testing.TB, spec synth.Spec) (*synth.Result, map[string]metrics.Churn) {
+	t.Helper()
+	result, err := synth.Build(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := result.Repository.CommitObject(plumbing.NewHash(result.Head))
+	if err != nil {
+		t.Fatal(err)
+	}
+	churns, err := metrics.Churns(context.Background(), head, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]metrics.Churn, len(churns))
+	for _, churn := range churns {
+		got[churn.CommitID] = churn
+	}
+	return result, got
+}
+
+func TestCommitStatsGolden(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3, 42} {
+		result, got := churnsOf(t, linearSpec(seed))
+		for _, want := range result.Churns {
+			churn, ok := got[want.CommitID]
+			if !ok {
+				t.Errorf("seed %d: no churn for %s", seed, want.CommitID)
+				continue
+			}
+			if churn.Stats != want.Stats {
+				t.Errorf("seed %d: %s %q: stats %+v, want %+v", seed, want.CommitID[:8], want.CommitMessage,
+					churn.Stats, want.Stats)
+			}
+			if !reflect.DeepEqual(churn.ChurnFiles, want.ChurnFiles) {
+				t.Errorf("seed %d: %s %q: churn files %+v, want %+v", seed, want.CommitID[:8],
+					want.CommitMessage, churn.ChurnFiles, want.ChurnFiles)
+			}
+		}
+	}
+}
@@ -1,9 +1,19 @@
 package metrics
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/ashishgalagali/go-git-churn/linediff"
+)
 
 const defaultDotGitPath = ".git"
 
+// DiffAlgorithm is the algorithm of the line diffs. Like the diff of go-git
+// it replaced, Myers does not slide the changes to the positions git's indent
+// heuristic prefers, so the lines of an ambiguous change may be attributed
+// differently than by git blame.
+var DiffAlgorithm = linediff.Myers
+
 // countLines returns the number of lines in a string à la git, this is
 // The newline character is assumed to be '\n'.  The empty string
 // contains 0 lines.  If the last line of the string doesn't end with a
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// GroupModule groups the files by module, the nearest directory below the
+// root with a manifest, or else the top directory.
+const GroupModule = "module"
+
+// CouplingGroups are all the groups co-change can be computed for.
+var CouplingGroups = []string{GroupFile, GroupDirectory, GroupModule}
+
+// moduleManifests are the files that make their directory a module.
+var moduleManifests = map[string]bool{
+	"go.mod": true, "package.json": true, "pom.xml": true, "build.gradle": true, "build.gradle.kts": true,
+	"Cargo.toml": true, "setup.py": true, "pyproject.toml": true, "composer.json": true, "Gemfile": true,
+	"CMakeLists.txt": true,
+}
+
+// CouplingOptions describes the commits and the groups co-change is computed
+// for.
+type CouplingOptions struct {
+	// Group is GroupFile, GroupDirectory or GroupModule.
+	Group string
+	// ModuleDepth, if not 0, makes the modules the directories at this depth
+	// instead of the ones with a manifest.
+	ModuleDepth int
+	// Since and Until, if not zero, exclude the commits authored before and
+	// after them.
+	Since, Until time.Time
+	// MaxFiles, if not 0, excludes the commits changing more files.
+	MaxFiles int
+	// MinCount is the number of co-changes below which a pair is left out.
+	MinCount int
+	// Prefix, if not empty, restricts the files to the ones below it.
+	Prefix string
+}
+
+// Coupling is the co-change of a pair of files, directories or modules.
+type Coupling struct {
+	A, B string
+	// Count is the number of commits changing both A and B, and CountA and
+	// CountB the number of commits changing each.
+	Count  int
+	CountA int
+	CountB int
+	// Support is the share of the commits changing both A and B.
+	Support float64
+	// ConfidenceAB is the probability that a commit changing A changes B,
+	// and ConfidenceBA the reverse.
+	ConfidenceAB float64
+	ConfidenceBA float64
+	// Degree is the degree of coupling: the share of the commits changing A
+	// or B that change both.
+	Degree float64
+}
+
+// CouplingResult is the co-change of the pairs changed together.
+type CouplingResult struct {
+	// Commits is the number of commits counted, and Skipped the number of
+	// the ones changing more than MaxFiles files.
+	Commits int
+	Skipped int
+	// Pairs are sorted by decreasing degree of coupling, then count.
+	Pairs []Coupling
+}
+
+// Couplings returns the co-change of the pairs of files, directories or
+// modules changed together by the commits in the history of commit c. The
+// merges are left out.
+func Couplings(ctx context.Context, c *object.Commit, opts CouplingOptions) (*CouplingResult, error) {
+	group, err := couplingGroup(c, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	type pair struct{ a, b string }
+	counts := make(map[string]int)
+	pairs := make(map[pair]int)
+	result := new(CouplingResult)
+	p := newProgress(ctx, PhaseWalking, 0)
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	defer iter.Close()
+	for walked := 1; ; walked++ {
+		commit, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, wrapError(err, c.Hash.String(), "")
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if walked%walkProgressEvery == 0 {
+			p.report(walked, "")
+		}
+		when := commit.Author.When
+		if commit.NumParents() > 1 || !opts.Since.IsZero() && when.Before(opts.Since) ||
+			!opts.Until.IsZero() && when.After(opts.Until) {
+			continue
+		}
+		files, err := changedFiles(ctx, commit)
+		if err != nil {
+			return nil, wrapError(err, commit.Hash.String(), "")
+		}
+		keys := make(map[string]struct{})
+		n := 0
+		for _, file := range files {
+			if hasPathPrefix(file, opts.Prefix) {
+				keys[group(file)] = struct{}{}
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		if opts.MaxFiles > 0 && n > opts.MaxFiles {
+			result.Skipped++
+			continue
+		}
+		result.Commits++
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+			counts[key]++
+		}
+		sort.Strings(sorted)
+		for i := range sorted {
+			for j := i + 1; j < len(sorted); j++ {
+				pairs[pair{sorted[i], sorted[j]}]++
+			}
+		}
+	}
+
+	for pr, count := range pairs {
+		if count < opts.MinCount {
+			continue
+		}
+		countA, countB := counts[pr.a], counts[pr.b]
+		result.Pairs = append(result.Pairs, Coupling{
+			A:            pr.a,
+			B:            pr.b,
+			Count:        count,
+			CountA:       countA,
+			CountB:       countB,
+			Support:      float64(count) / float64(result.Commits),
+			ConfidenceAB: float64(count) / float64(countA),
+			ConfidenceBA: float64(count) / float64(countB),
+			Degree:       float64(count) / float64(countA+countB-count),
+		})
+	}
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		a, b := result.Pairs[i], result.Pairs[j]
+		switch {
+		case a.Degree != b.Degree:
+			return a.Degree > b.Degree
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		case a.A != b.A:
+			return a.A < b.A
+		default:
+			return a.B < b.B
+		}
+	})
+	return result, nil
+}
+
+// changedFiles returns the files changed by the commit c against its first
+// parent, with their new name if they are renamed.
+func changedFiles(ctx context.Context, c *object.Commit) ([]string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	parentTree := &object.Tree{}
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, parentTree, tree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		if action == merkletrie.Delete {
+			files = append(files, change.From.Name)
+		} else {
+			files = append(files, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+// couplingGroup returns the function returning the group of a file.
+func couplingGroup(c *object.Commit, opts CouplingOptions) (func(string) string, error) {
+	switch opts.Group {
+	case GroupFile, "":
+		return func(file string) string { return file }, nil
+	case GroupDirectory:
+		return path.Dir, nil
+	case GroupModule:
+	default:
+		return nil, fmt.Errorf("unknown group %q, must be %s", opts.Group, strings.Join(CouplingGroups, ", "))
+	}
+
+	if opts.ModuleDepth > 0 {
+		return func(file string) string {
+			parts := strings.Split(path.Dir(file), "/")
+			if len(parts) > opts.ModuleDepth {
+				parts = parts[:opts.ModuleDepth]
+			}
+			return strings.Join(parts, "/")
+		}, nil
+	}
+	modules := make(map[string]bool)
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, wrapError(err, c.Hash.String(), "")
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if dir := path.Dir(f.Name); dir != "." && moduleManifests[path.Base(f.Name)] {
+			modules[dir] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapError(err, c.Hash.String(), "")
+	}
+	return func(file string) string {
+		for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+			if modules[dir] {
+				return dir
+			}
+		}
+		return strings.SplitN(path.Dir(file), "/", 2)[0]
+	}, nil
+}
@@ -0,0 +1,110 @@
+package metrics_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// couplingRepo builds a history whose commits change the given files, and
+// returns them.
+func couplingRepo(t *testing.T, commits ...string) []*object.Commit {
+	tr := newTestRepo(t)
+	var result []*object.Commit
+	for i, files := range commits {
+		changes := make(map[string]string)
+		for _, name := range strings.Fields(files) {
+			changes[name] = strings.Repeat("change\n", i+1)
+		}
+		result = append(result, tr.commit("alice", changes))
+	}
+	return result
+}
+
+// checkPairs checks the pairs of the result, in order, against the wanted
+// ones.
+func checkPairs(t *testing.T, name string, result *metrics.CouplingResult, want ...metrics.Coupling) {
+	t.Helper()
+	if len(result.Pairs) != len(want) {
+		t.Errorf("%s: pairs %+v, want %+v", name, result.Pairs, want)
+		return
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for i, got := range result.Pairs {
+		w := want[i]
+		if got.A != w.A || got.B != w.B || got.Count != w.Count || got.CountA != w.CountA || got.CountB != w.CountB ||
+			!near(got.Support, w.Support) || !near(got.ConfidenceAB, w.ConfidenceAB) ||
+			!near(got.ConfidenceBA, w.ConfidenceBA) || !near(got.Degree, w.Degree) {
+			t.Errorf("%s: pair %d %+v, want %+v", name, i, got, w)
+		}
+	}
+}
+
+func TestCouplings(t *testing.T) {
+	commits := couplingRepo(t,
+		"api/go.mod api/server/h.go api/client.go web/app.js web/style.css README.md",
+		"api/server/h.go api/client.go",
+		"api/server/h.go api/client.go web/app.js",
+		"api/server/h.go",
+		"web/app.js web/style.css",
+		"README.md web/app.js",
+	)
+	head := commits[len(commits)-1]
+	couplings := func(opts metrics.CouplingOptions) *metrics.CouplingResult {
+		t.Helper()
+		result, err := metrics.Couplings(context.Background(), head, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// the first commit changes too many files
+	result := couplings(metrics.CouplingOptions{MaxFiles: 5})
+	if result.Commits != 5 || result.Skipped != 1 {
+		t.Errorf("%d commits and %d skipped, want 5 and 1", result.Commits, result.Skipped)
+	}
+	checkPairs(t, "files", result,
+		metrics.Coupling{A: "api/client.go", B: "api/server/h.go", Count: 2, CountA: 2, CountB: 3,
+			Support: 2.0 / 5, ConfidenceAB: 1, ConfidenceBA: 2.0 / 3, Degree: 2.0 / 3},
+		metrics.Coupling{A: "README.md", B: "web/app.js", Count: 1, CountA: 1, CountB: 3,
+			Support: 1.0 / 5, ConfidenceAB: 1, ConfidenceBA: 1.0 / 3, Degree: 1.0 / 3},
+		metrics.Coupling{A: "web/app.js", B: "web/style.css", Count: 1, CountA: 3, CountB: 1,
+			Support: 1.0 / 5, ConfidenceAB: 1.0 / 3, ConfidenceBA: 1, Degree: 1.0 / 3},
+		metrics.Coupling{A: "api/client.go", B: "web/app.js", Count: 1, CountA: 2, CountB: 3,
+			Support: 1.0 / 5, ConfidenceAB: 1.0 / 2, ConfidenceBA: 1.0 / 3, Degree: 1.0 / 4},
+		metrics.Coupling{A: "api/server/h.go", B: "web/app.js", Count: 1, CountA: 3, CountB: 3,
+			Support: 1.0 / 5, ConfidenceAB: 1.0 / 3, ConfidenceBA: 1.0 / 3, Degree: 1.0 / 5},
+	)
+	checkPairs(t, "min count", couplings(metrics.CouplingOptions{MaxFiles: 5, MinCount: 2}), result.Pairs[0])
+
+	// the window keeps the third to the fifth commits
+	result = couplings(metrics.CouplingOptions{Since: commits[2].Author.When, Until: commits[4].Author.When})
+	if result.Commits != 3 || len(result.Pairs) != 4 {
+		t.Errorf("window: %d commits and pairs %+v, want 3 commits and 4 pairs", result.Commits, result.Pairs)
+	}
+
+	checkPairs(t, "directories", couplings(metrics.CouplingOptions{Group: metrics.GroupDirectory, MaxFiles: 5,
+		MinCount: 2}),
+		metrics.Coupling{A: "api", B: "api/server", Count: 2, CountA: 2, CountB: 3,
+			Support: 2.0 / 5, ConfidenceAB: 1, ConfidenceBA: 2.0 / 3, Degree: 2.0 / 3})
+
+	// api has a manifest, web is a top directory
+	modules := []metrics.Coupling{
+		{A: ".", B: "web", Count: 1, CountA: 1, CountB: 3,
+			Support: 1.0 / 5, ConfidenceAB: 1, ConfidenceBA: 1.0 / 3, Degree: 1.0 / 3},
+		{A: "api", B: "web", Count: 1, CountA: 3, CountB: 3,
+			Support: 1.0 / 5, ConfidenceAB: 1.0 / 3, ConfidenceBA: 1.0 / 3, Degree: 1.0 / 5},
+	}
+	checkPairs(t, "modules", couplings(metrics.CouplingOptions{Group: metrics.GroupModule, MaxFiles: 5}), modules...)
+	checkPairs(t, "modules at depth 1", couplings(metrics.CouplingOptions{Group: metrics.GroupModule,
+		ModuleDepth: 1, MaxFiles: 5}), modules...)
+
+	if _, err := metrics.Couplings(context.Background(), head, metrics.CouplingOptions{Group: "team"}); err == nil {
+		t.Error("unknown group: no error")
+	}
+}
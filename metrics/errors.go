@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// The kinds of errors returned by the package. Use errors.Is to tell the kind
+// of an error.
+var (
+	ErrRepositoryNotFound = errors.New("repository not found")
+	ErrRevisionNotFound   = errors.New("revision not found")
+	ErrObjectMissing      = errors.New("object missing")
+	ErrPathNotInHistory   = errors.New("path not in history")
+	ErrCancelled          = errors.New("cancelled")
+)
+
+// Error is an error that happened while processing a commit or a path.
+type Error struct {
+	// Kind is one of the kinds of errors above, nil if it is unknown.
+	Kind error
+	// Commit is the commit, or the revision, being processed, if any.
+	Commit string
+	// Path is the path being processed, if any.
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var context []string
+	if e.Commit != "" {
+		context = append(context, "commit "+e.Commit)
+	}
+	if e.Path != "" {
+		context = append(context, "path "+e.Path)
+	}
+	msg := e.Err.Error()
+	if e.Kind != nil && !strings.Contains(msg, e.Kind.Error()) {
+		msg = e.Kind.Error() + ": " + msg
+	}
+	if len(context) == 0 {
+		return msg
+	}
+	return strings.Join(context, ", ") + ": " + msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is tells if the error is of the kind target.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// wrapError returns err with the commit and path it happened on, and its kind.
+// Errors that already have a commit or a path are returned as they are.
+func wrapError(err error, commit, path string) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) && (e.Commit != "" || e.Path != "") {
+		return err
+	}
+	var interrupted *InterruptedError
+	if errors.As(err, &interrupted) {
+		return err
+	}
+	return &Error{
+		Kind:   errorKind(err),
+		Commit: commit,
+		Path:   path,
+		Err:    err,
+	}
+}
+
+// errorKind classifies the errors of go-git and of the contexts.
+func errorKind(err error) error {
+	for _, kind := range []error{ErrRepositoryNotFound, ErrRevisionNotFound, ErrObjectMissing,
+		ErrPathNotInHistory, ErrCancelled} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	switch {
+	case errors.Is(err, transport.ErrRepositoryNotFound), errors.Is(err, git.ErrRepositoryNotExists),
+		errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return ErrRepositoryNotFound
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		return ErrRevisionNotFound
+	case errors.Is(err, plumbing.ErrObjectNotFound):
+		return ErrObjectMissing
+	case errors.Is(err, object.ErrFileNotFound), errors.Is(err, object.ErrDirectoryNotFound):
+		return ErrPathNotInHistory
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrCancelled
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// HotspotWeights are the weights of the factors of the hotspot score. Every
+// factor is normalized to [0, 1] before it is weighted.
+type HotspotWeights struct {
+	// Frequency weighs the number of commits changing the file.
+	Frequency float64
+	// Interactive weighs the share of the churn of the file that is
+	// interactive.
+	Interactive float64
+	// Authors weighs the number of distinct authors changing the file.
+	Authors float64
+	// Lines weighs the number of lines of the file.
+	Lines float64
+	// Complexity weighs the external complexity of the file, if any.
+	Complexity float64
+}
+
+// DefaultHotspotWeights weigh all the factors equally.
+var DefaultHotspotWeights = HotspotWeights{Frequency: 1, Interactive: 1, Authors: 1, Lines: 1, Complexity: 1}
+
+// HotspotOptions describes the commits and the factors of the hotspot
+// scores.
+type HotspotOptions struct {
+	// Since and Until, if not zero, exclude the commits authored before and
+	// after them.
+	Since, Until time.Time
+	// Prefix, if not empty, restricts the files to the ones below it.
+	Prefix string
+	// Complexity, if not nil, is the complexity of the files, e.g. their
+	// cyclomatic complexity measured by another tool.
+	Complexity map[string]float64
+	Weights    HotspotWeights
+}
+
+// Hotspot is a file of the final revision and the factors of its score.
+type Hotspot struct {
+	File string
+	// Lines is the number of lines of the file.
+	Lines int
+	// Commits is the number of commits changing the file, and Authors the
+	// number of distinct authors of these commits.
+	Commits int
+	Authors int
+	// SelfChurn and InteractiveChurn are the numbers of lines of the file
+	// deleted by their author and by other authors.
+	SelfChurn        int
+	InteractiveChurn int
+	// InteractiveShare is the share of the churn that is interactive.
+	InteractiveShare float64
+	Complexity       float64 `json:",omitempty"`
+	// Score is the weighted mean of the normalized factors, in [0, 1].
+	Score float64
+}
+
+// Hotspots returns the files of commit c ranked by decreasing hotspot score,
+// computed from the commits of its history in the time window. The merges are
+// left out.
+func Hotspots(ctx context.Context, c *object.Commit, opts HotspotOptions) ([]Hotspot, error) {
+	byFile := make(map[string]*Hotspot)
+	authors := make(map[string]map[string]struct{})
+	hotspot := func(file string) *Hotspot {
+		h, ok := byFile[file]
+		if !ok {
+			h = &Hotspot{File: file}
+			byFile[file] = h
+			authors[file] = make(map[string]struct{})
+		}
+		return h
+	}
+
+	// walkErr is the first error of the churn callback
+	var walkErr error
+	b := new(blame)
+	b.fRev = c
+	b.onChurn = func(churn Churn) {
+		rev := b.revs[b.commitIndexMap[churn.CommitID]]
+		when := rev.Author.When
+		if walkErr != nil || rev.NumParents() > 1 || !opts.Since.IsZero() && when.Before(opts.Since) ||
+			!opts.Until.IsZero() && when.After(opts.Until) {
+			return
+		}
+		files, err := changedFiles(ctx, rev)
+		if err != nil {
+			walkErr = wrapError(err, churn.CommitID, "")
+			return
+		}
+		for _, file := range files {
+			h := hotspot(file)
+			h.Commits++
+			authors[file][rev.Author.Email] = struct{}{}
+		}
+		for _, f := range churn.ChurnFiles {
+			h := hotspot(f.FileName)
+			h.SelfChurn += len(f.SelfChurn)
+			for _, lines := range f.InteractiveChurn {
+				h.InteractiveChurn += len(lines)
+			}
+		}
+	}
+	if err := b.fillRevs(ctx); err != nil {
+		return nil, err
+	}
+	if err := b.fillGraphAndData(ctx); err != nil {
+		return nil, err
+	}
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	i, ok := b.commitIndexMap[c.Hash.String()]
+	if !ok {
+		return nil, nil
+	}
+	var result []Hotspot
+	for name, revs := range b.graph {
+		if revs[i] == nil || !hasPathPrefix(name, opts.Prefix) {
+			continue
+		}
+		h := hotspot(name)
+		h.Lines = len(revs[i])
+		h.Authors = len(authors[name])
+		if churn := h.SelfChurn + h.InteractiveChurn; churn != 0 {
+			h.InteractiveShare = float64(h.InteractiveChurn) / float64(churn)
+		}
+		h.Complexity = opts.Complexity[name]
+		result = append(result, *h)
+	}
+	score(result, opts)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].File < result[j].File
+	})
+	return result, nil
+}
+
+// score sets the scores of the hotspots: the weighted mean of their factors,
+// each divided by its maximum. The complexity is not a factor without
+// complexities.
+func score(hotspots []Hotspot, opts HotspotOptions) {
+	var maxCommits, maxAuthors, maxLines int
+	var maxShare, maxComplexity float64
+	for _, h := range hotspots {
+		maxCommits = max(maxCommits, h.Commits)
+		maxAuthors = max(maxAuthors, h.Authors)
+		maxLines = max(maxLines, h.Lines)
+		if h.InteractiveShare > maxShare {
+			maxShare = h.InteractiveShare
+		}
+		if h.Complexity > maxComplexity {
+			maxComplexity = h.Complexity
+		}
+	}
+	ratio := func(value, max float64) float64 {
+		if max == 0 {
+			return 0
+		}
+		return value / max
+	}
+
+	w := opts.Weights
+	total := w.Frequency + w.Interactive + w.Authors + w.Lines
+	if opts.Complexity != nil {
+		total += w.Complexity
+	}
+	if total == 0 {
+		return
+	}
+	for i := range hotspots {
+		h := &hotspots[i]
+		s := w.Frequency*ratio(float64(h.Commits), float64(maxCommits)) +
+			w.Interactive*ratio(h.InteractiveShare, maxShare) +
+			w.Authors*ratio(float64(h.Authors), float64(maxAuthors)) +
+			w.Lines*ratio(float64(h.Lines), float64(maxLines))
+		if opts.Complexity != nil {
+			s += w.Complexity * ratio(h.Complexity, maxComplexity)
+		}
+		h.Score = s / total
+	}
+}
@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// The files are ranked by the commits, authors and lines changing them and
+// by the share of their churn that is interactive, which counts every author
+// whose lines a commit deletes.
+func TestHotspotsRanking(t *testing.T) {
+	tr := newTestRepo(t)
+	a := lines("a", 10)
+	tr.commit("alice", map[string]string{"a.txt": a, "b.txt": lines("b", 10)})
+	a = strings.Replace(a, "a1\n", "x1\n", 1)
+	tr.commit("bob", map[string]string{"a.txt": a})
+	// deletes a line of alice and a line of bob
+	a = strings.Replace(strings.Replace(a, "x1\n", "y1\n", 1), "a2\n", "y2\n", 1)
+	tr.commit("carol", map[string]string{"a.txt": a})
+	b := strings.Replace(strings.Replace(lines("b", 10), "b1\n", "z1\n", 1), "b2\n", "z2\n", 1)
+	tr.commit("alice", map[string]string{"b.txt": b})
+	head := tr.commit("dave", map[string]string{"c.txt": lines("c", 5)})
+
+	hotspots, err := metrics.Hotspots(context.Background(), head,
+		metrics.HotspotOptions{Weights: metrics.DefaultHotspotWeights})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []metrics.Hotspot{
+		{File: "a.txt", Lines: 10, Commits: 3, Authors: 3, InteractiveChurn: 3, InteractiveShare: 1, Score: 1},
+		{File: "b.txt", Lines: 10, Commits: 2, Authors: 1, SelfChurn: 2, Score: (2.0/3 + 1.0/3 + 1) / 4},
+		{File: "c.txt", Lines: 5, Commits: 1, Authors: 1, Score: (1.0/3 + 1.0/3 + 0.5) / 4},
+	}
+	if len(hotspots) != len(want) {
+		t.Fatalf("%d hotspots %+v, want %d", len(hotspots), hotspots, len(want))
+	}
+	for i, h := range hotspots {
+		score := h.Score
+		h.Score = want[i].Score
+		if h != want[i] || math.Abs(score-want[i].Score) > 1e-9 {
+			h.Score = score
+			t.Errorf("hotspot %d: %+v, want %+v", i, h, want[i])
+		}
+	}
+
+	// by interactive share only, the files without interactive churn tie
+	hotspots, err = metrics.Hotspots(context.Background(), head,
+		metrics.HotspotOptions{Weights: metrics.HotspotWeights{Interactive: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, h := range hotspots {
+		order = append(order, h.File)
+	}
+	if got := strings.Join(order, " "); got != "a.txt b.txt c.txt" || hotspots[1].Score != 0 {
+		t.Errorf("ranking by interactive share %q, scores %v, want a.txt b.txt c.txt", got, hotspots)
+	}
+}
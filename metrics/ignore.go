@@ -0,0 +1,217 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/ashishgalagali/go-git-churn/helper"
+	"github.com/ashishgalagali/go-git-churn/linediff"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// IgnoreRevsFile is the file of the repository listing the commits ignored by
+// default, as in git.
+const IgnoreRevsFile = ".git-blame-ignore-revs"
+
+// IgnoreOptions describes the commits whose changes are not attributed to
+// them, like git blame --ignore-rev. The lines they change keep the origin of
+// the lines of the parent they match, and they have no churn.
+type IgnoreOptions struct {
+	// Revs are the hashes, or unique prefixes of at least 4 characters, of
+	// the ignored commits. They must be in the history.
+	Revs []string
+	// ListedRevs are the ones listed in files, e.g. with ReadIgnoreRevs,
+	// which may list commits of other histories.
+	ListedRevs []string
+	// NoDefaultFile disables the commits listed in the IgnoreRevsFile of the
+	// walked commit.
+	NoDefaultFile bool
+}
+
+// Ignore describes the ignored commits.
+var Ignore IgnoreOptions
+
+// ignoreWhitespace compares the lines of the changes of the ignored commits.
+var ignoreWhitespace = MoveOptions{IgnoreWhitespace: true}
+
+// ReadIgnoreRevs returns the commits listed in r, one per line. Blank lines
+// and the text after a # are skipped.
+func ReadIgnoreRevs(r io.Reader) ([]string, error) {
+	var revs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(line); len(fields) != 0 {
+			revs = append(revs, fields[0])
+		}
+	}
+	return revs, scanner.Err()
+}
+
+// ignoredRevs returns the indices in b.revs of the ignored commits, the ones
+// of Ignore and of the IgnoreRevsFile of b.fRev. The revisions of Ignore.Revs
+// not in the history are errors, the listed ones are skipped.
+func (b *blame) ignoredRevs() (map[int]bool, error) {
+	revs := Ignore.ListedRevs
+	if !Ignore.NoDefaultFile {
+		file, err := b.fRev.File(IgnoreRevsFile)
+		switch {
+		case err == object.ErrFileNotFound:
+		case err != nil:
+			return nil, wrapError(err, b.fRev.Hash.String(), IgnoreRevsFile)
+		default:
+			contents, err := file.Contents()
+			if err != nil {
+				return nil, wrapError(err, b.fRev.Hash.String(), IgnoreRevsFile)
+			}
+			listed, err := ReadIgnoreRevs(strings.NewReader(contents))
+			if err != nil {
+				return nil, err
+			}
+			revs = append(listed, revs...)
+		}
+	}
+	if len(revs) == 0 && len(Ignore.Revs) == 0 {
+		return nil, nil
+	}
+
+	ignored := make(map[int]bool)
+	var missing []string
+	for _, rev := range Ignore.Revs {
+		if !b.matchRev(rev, ignored) {
+			missing = append(missing, rev)
+		}
+	}
+	if err := b.checkInHistory(missing); err != nil {
+		return nil, err
+	}
+	for _, rev := range revs {
+		if !b.matchRev(rev, ignored) {
+			helper.Debug("ignored revision not in history", "rev", rev)
+		}
+	}
+	return ignored, nil
+}
+
+// matchRev adds to revs the indices in b.revs of the commits whose hash starts
+// with the prefix rev of at least 4 characters, and tells if there are any.
+func (b *blame) matchRev(rev string, revs map[int]bool) bool {
+	matched := false
+	if len(rev) >= 4 {
+		for i, c := range b.revs {
+			if strings.HasPrefix(c.Hash.String(), strings.ToLower(rev)) {
+				revs[i] = true
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+// checkInHistory returns an error if one of revs is not the prefix of at least
+// 4 characters of a commit of the walked history, even if the path leaves it
+// out of b.revs.
+func (b *blame) checkInHistory(revs []string) error {
+	if len(revs) == 0 {
+		return nil
+	}
+	tips := []*object.Commit{b.fRev}
+	if len(b.tips) != 0 {
+		tips = make([]*object.Commit, len(b.tips))
+		for i, tip := range b.tips {
+			tips[i] = tip.Commit
+		}
+	}
+	found := make(map[string]bool)
+	seen := make(map[plumbing.Hash]bool)
+	for _, tip := range tips {
+		err := object.NewCommitPreorderIter(tip, seen, nil).ForEach(func(c *object.Commit) error {
+			for _, rev := range revs {
+				if len(rev) >= 4 && strings.HasPrefix(c.Hash.String(), strings.ToLower(rev)) {
+					found[rev] = true
+				}
+			}
+			if len(found) == len(revs) {
+				return storer.ErrStop
+			}
+			return nil
+		})
+		if err != nil {
+			return wrapError(err, tip.Hash.String(), "")
+		}
+	}
+	for _, rev := range revs {
+		if !found[rev] {
+			return &Error{Kind: ErrRevisionNotFound, Commit: rev, Err: errors.New("ignored revision not in history")}
+		}
+	}
+	return nil
+}
+
+// ignoreOrigins assigns to the lines of the file of the ignored revision c the
+// origin of the lines of its parent p they match, given the hunks of their
+// diff and the lines moved or copied. In every change, the lines inserted
+// take the origin of the line deleted with the same text, ignoring
+// whitespace, or else of the line deleted at the same relative position, or
+// else of the line of the parent before the change.
+func (b *blame) ignoreOrigins(c, p int, name string, hunks []linediff.Hunk, moves *moveSet) {
+	graph := b.graph[name]
+	sl, dl := 0, 0
+	for h := 0; h < len(hunks); {
+		if hunks[h].Op == linediff.Equal {
+			for range hunks[h].Lines {
+				graph[c][dl] = graph[p][sl]
+				sl++
+				dl++
+			}
+			h++
+			continue
+		}
+
+		// a change is the hunks between two equal ones
+		var deleted, inserted []diffLine
+		for ; h < len(hunks) && hunks[h].Op != linediff.Equal; h++ {
+			for _, line := range hunks[h].Lines {
+				if hunks[h].Op == linediff.Delete {
+					deleted = append(deleted, diffLine{lineRef{name, sl}, line})
+					sl++
+				} else {
+					inserted = append(inserted, diffLine{lineRef{name, dl}, line})
+					dl++
+				}
+			}
+		}
+		matches := make(map[string][]int)
+		for i, l := range deleted {
+			key := ignoreWhitespace.key(l.text)
+			matches[key] = append(matches[key], i)
+		}
+		for i, l := range inserted {
+			if from, ok := moves.origin(name, l.n); ok {
+				graph[c][l.n] = b.graph[from.file][p][from.n]
+				continue
+			}
+			key := ignoreWhitespace.key(l.text)
+			switch {
+			case len(matches[key]) != 0:
+				graph[c][l.n] = graph[p][deleted[matches[key][0]].n]
+				matches[key] = matches[key][1:]
+			case len(deleted) != 0:
+				graph[c][l.n] = graph[p][deleted[i*len(deleted)/len(inserted)].n]
+			case l.n-i > 0:
+				graph[c][l.n] = graph[c][l.n-i-1]
+			case sl < len(graph[p]):
+				graph[c][l.n] = graph[p][sl]
+			default:
+				graph[c][l.n] = b.revs[c]
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// withIgnore sets metrics.Ignore for the test.
+func withIgnore(t *testing.T, ignore metrics.IgnoreOptions) {
+	saved := metrics.Ignore
+	metrics.Ignore = ignore
+	t.Cleanup(func() { metrics.Ignore = saved })
+}
+
+// The ignored revisions given explicitly must be in the history, even if the
+// path leaves them out, while the listed ones may not be.
+func TestIgnoredRevsNotInHistory(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("alice", map[string]string{"a.txt": lines("a", 3)})
+	other := tr.commit("bob", map[string]string{"b.txt": lines("b", 3)})
+	head := tr.commit("carol", map[string]string{"a.txt": lines("a", 4)})
+	missing := "deadbeef"
+
+	tests := []struct {
+		name   string
+		ignore metrics.IgnoreOptions
+		path   string
+		err    error
+	}{
+		{"explicit", metrics.IgnoreOptions{Revs: []string{missing}}, "", metrics.ErrRevisionNotFound},
+		{"too short", metrics.IgnoreOptions{Revs: []string{other.Hash.String()[:3]}}, "", metrics.ErrRevisionNotFound},
+		{"listed", metrics.IgnoreOptions{ListedRevs: []string{missing}}, "", nil},
+		{"left out by the path", metrics.IgnoreOptions{Revs: []string{other.Hash.String()[:7]}}, "a.txt", nil},
+	}
+	for _, test := range tests {
+		withIgnore(t, test.ignore)
+		_, err := metrics.Churns(context.Background(), head, &metrics.ChurnOptions{Path: test.path})
+		if test.err == nil && err != nil || test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s: %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+// The lines of a reformatting commit listed in the IgnoreRevsFile keep the
+// authors of the lines they match, whose later deletions are their churn.
+func TestIgnoredReformatting(t *testing.T) {
+	withIgnore(t, metrics.IgnoreOptions{})
+	tr := newTestRepo(t)
+	tr.commit("alice", map[string]string{"a.txt": lines("a", 4)})
+	tr.commit("bob", map[string]string{"a.txt": "a1\nb2\na3\na4\n"})
+	// reindents and reorders the lines
+	reformat := tr.commit("carol", map[string]string{"a.txt": "  a1\n  a4\n  b2\n  a3\n"})
+	if got, want := ownersOf(t, reformat)["a.txt"], map[string]int{"carol": 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("owners before the reformatting is ignored %v, want %v", got, want)
+	}
+	head := tr.commit("dave", map[string]string{
+		"a.txt":                "  a1\n  a3\n",
+		metrics.IgnoreRevsFile: "# reformatting\n" + reformat.Hash.String() + "\n",
+	})
+
+	if got, want := ownersOf(t, head)["a.txt"], map[string]int{"alice": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("owners %v, want %v", got, want)
+	}
+	churns, err := metrics.Churns(context.Background(), head, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := churns[2]; !c.Ignored || len(c.ChurnFiles) != 0 {
+		t.Errorf("churn of the reformatting %+v, want it ignored", c)
+	}
+	var deleted map[string][]int
+	for _, f := range churns[3].ChurnFiles {
+		if f.FileName == "a.txt" {
+			deleted = f.InteractiveChurn
+		}
+	}
+	if want := map[string][]int{"alice": {2}, "bob": {3}}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("lines deleted after the reformatting %v, want %v", deleted, want)
+	}
+}
+
+// The lines of an ignored commit that match no line take the origin of the
+// line deleted at the same position, or else of the line before them, or else
+// of the first line after them, or else the ignored commit.
+func TestIgnoredOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		ignored map[string]string
+		want    map[string]map[string]int
+	}{
+		{"changed line", map[string]string{"a.txt": "a1\nx\na3\n"},
+			map[string]map[string]int{"a.txt": {"alice": 2, "bob": 1}}},
+		{"inserted line", map[string]string{"a.txt": "a1\nb2\nx\na3\n"},
+			map[string]map[string]int{"a.txt": {"alice": 2, "bob": 2}}},
+		{"inserted first line", map[string]string{"a.txt": "x\na1\nb2\na3\n"},
+			map[string]map[string]int{"a.txt": {"alice": 3, "bob": 1}}},
+		{"new file", map[string]string{"new.txt": "x\n"},
+			map[string]map[string]int{"a.txt": {"alice": 2, "bob": 1}, "new.txt": {"carol": 1}}},
+	}
+	for _, test := range tests {
+		tr := newTestRepo(t)
+		tr.commit("alice", map[string]string{"a.txt": lines("a", 3)})
+		tr.commit("bob", map[string]string{"a.txt": "a1\nb2\na3\n"})
+		head := tr.commit("carol", test.ignored)
+		withIgnore(t, metrics.IgnoreOptions{Revs: []string{head.Hash.String()}})
+		if got := ownersOf(t, head); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: owners %v, want %v", test.name, got, test.want)
+		}
+	}
+}
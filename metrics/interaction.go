@@ -0,0 +1,270 @@
+package metrics
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// InteractionGraph is the weighted directed network of the interactive churn
+// between authors. An edge goes from the author that deleted the lines to the
+// author that wrote them.
+type InteractionGraph struct {
+	Nodes []InteractionNode
+	Edges []InteractionEdge
+}
+
+// InteractionNode is an author of the interaction graph.
+type InteractionNode struct {
+	Author string
+	// Degree is the number of distinct authors this author interacted with,
+	// in either direction.
+	Degree    int
+	InDegree  int
+	OutDegree int
+	// InStrength is the number of lines of this author deleted by others and
+	// OutStrength the number of lines of others deleted by this author.
+	InStrength  int
+	OutStrength int
+	// Betweenness is the betweenness centrality of the author, computed
+	// over the unweighted directed graph.
+	Betweenness float64
+}
+
+// InteractionEdge tells that From deleted Lines lines written by To.
+type InteractionEdge struct {
+	From  string
+	To    string
+	Lines int
+}
+
+// NewInteractionGraph builds the interaction graph of the given churns. Every
+// commit author is a node, even if they did not interact with anyone.
+func NewInteractionGraph(churns []Churn) *InteractionGraph {
+	weights := make(map[string]map[string]int)
+	authors := make(map[string]struct{})
+	for _, churn := range churns {
+		authors[churn.CommitAuthor] = struct{}{}
+		for _, file := range churn.ChurnFiles {
+			for author, lines := range file.InteractiveChurn {
+				authors[author] = struct{}{}
+				if _, ok := weights[churn.CommitAuthor]; !ok {
+					weights[churn.CommitAuthor] = make(map[string]int)
+				}
+				weights[churn.CommitAuthor][author] += len(lines)
+			}
+		}
+	}
+
+	g := new(InteractionGraph)
+	index := make(map[string]int)
+	for author := range authors {
+		g.Nodes = append(g.Nodes, InteractionNode{Author: author})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].Author < g.Nodes[j].Author
+	})
+	for i, n := range g.Nodes {
+		index[n.Author] = i
+	}
+
+	neighbours := make([]map[int]struct{}, len(g.Nodes))
+	out := make([][]int, len(g.Nodes))
+	for i := range neighbours {
+		neighbours[i] = make(map[int]struct{})
+	}
+	for from, tos := range weights {
+		for to, lines := range tos {
+			g.Edges = append(g.Edges, InteractionEdge{From: from, To: to, Lines: lines})
+			f, t := index[from], index[to]
+			g.Nodes[f].OutDegree++
+			g.Nodes[f].OutStrength += lines
+			g.Nodes[t].InDegree++
+			g.Nodes[t].InStrength += lines
+			neighbours[f][t] = struct{}{}
+			neighbours[t][f] = struct{}{}
+			out[f] = append(out[f], t)
+		}
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+
+	betweenness := brandes(out)
+	for i := range g.Nodes {
+		g.Nodes[i].Degree = len(neighbours[i])
+		g.Nodes[i].Betweenness = betweenness[i]
+	}
+	return g
+}
+
+// brandes computes the betweenness centrality of every node of an unweighted
+// directed graph given as adjacency lists, as described in Brandes, "A Faster
+// Algorithm for Betweenness Centrality", Journal of Mathematical Sociology,
+// 2001.
+func brandes(out [][]int) []float64 {
+	cb := make([]float64, len(out))
+	for s := range out {
+		var stack []int
+		pred := make([][]int, len(out))
+		sigma := make([]float64, len(out))
+		dist := make([]int, len(out))
+		for i := range dist {
+			dist[i] = -1
+		}
+		sigma[s] = 1
+		dist[s] = 0
+		queue := []int{s}
+		for len(queue) != 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range out[v] {
+				if dist[w] < 0 {
+					queue = append(queue, w)
+					dist[w] = dist[v] + 1
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					pred[w] = append(pred[w], v)
+				}
+			}
+		}
+
+		delta := make([]float64, len(out))
+		for len(stack) != 0 {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, v := range pred[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				cb[w] += delta[w]
+			}
+		}
+	}
+	return cb
+}
+
+// WriteJSON writes the graph as JSON node and edge lists.
+func (g *InteractionGraph) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *InteractionGraph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph interactions {"); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		_, err := fmt.Fprintf(w, "  %s [degree=%d, in_strength=%d, out_strength=%d, betweenness=%s];\n",
+			strconv.Quote(n.Author), n.Degree, n.InStrength, n.OutStrength, formatFloat(n.Betweenness))
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		_, err := fmt.Fprintf(w, "  %s -> %s [weight=%d, label=%d];\n",
+			strconv.Quote(e.From), strconv.Quote(e.To), e.Lines, e.Lines)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph in the GraphML format.
+func (g *InteractionGraph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "degree", For: "node", AttrName: "degree", AttrType: "int"},
+			{ID: "in_strength", For: "node", AttrName: "in_strength", AttrType: "int"},
+			{ID: "out_strength", For: "node", AttrName: "out_strength", AttrType: "int"},
+			{ID: "betweenness", For: "node", AttrName: "betweenness", AttrType: "double"},
+			{ID: "weight", For: "edge", AttrName: "weight", AttrType: "int"},
+		},
+		Graph: graphMLGraph{EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.Author,
+			Data: []graphMLData{
+				{Key: "degree", Value: strconv.Itoa(n.Degree)},
+				{Key: "in_strength", Value: strconv.Itoa(n.InStrength)},
+				{Key: "out_strength", Value: strconv.Itoa(n.OutStrength)},
+				{Key: "betweenness", Value: formatFloat(n.Betweenness)},
+			},
+		})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.From,
+			Target: e.To,
+			Data:   []graphMLData{{Key: "weight", Value: strconv.Itoa(e.Lines)}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,135 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+func TestInteractionGraphEdges(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("alice", map[string]string{"f.txt": "a1\na2\na3\na4\n", "g.txt": "a5\na6\n"})
+	tr.commit("bob", map[string]string{"f.txt": "a1\na2\na3\na4\nb1\nb2\n"})
+	// carol deletes the lines of two authors in the same file
+	tr.commit("carol", map[string]string{"f.txt": "a3\na4\nb2\nc1\n"})
+	head := tr.commit("alice", map[string]string{"f.txt": "a3\na4\nc1\n", "g.txt": "a5\n"})
+
+	churns, err := metrics.Churns(context.Background(), head, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := metrics.NewInteractionGraph(churns)
+
+	wantEdges := []metrics.InteractionEdge{
+		{From: "alice", To: "bob", Lines: 1},
+		{From: "carol", To: "alice", Lines: 2},
+		{From: "carol", To: "bob", Lines: 1},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("edges %+v, want %+v", g.Edges, wantEdges)
+	}
+	strengths := make(map[string][2]int)
+	for _, n := range g.Nodes {
+		strengths[n.Author] = [2]int{n.InStrength, n.OutStrength}
+	}
+	wantStrengths := map[string][2]int{"alice": {2, 1}, "bob": {2, 0}, "carol": {0, 3}}
+	if !reflect.DeepEqual(strengths, wantStrengths) {
+		t.Errorf("in and out strengths %v, want %v", strengths, wantStrengths)
+	}
+}
+
+// The nodes have the same attributes in all the formats.
+func TestInteractionGraphFormats(t *testing.T) {
+	// erin is reached from dave through alice, and from carol through alice
+	// or bob
+	churns := []metrics.Churn{
+		{CommitAuthor: "dave", ChurnFiles: []metrics.ChurnFile{{InteractiveChurn: map[string][]int{"carol": {1}, "alice": {1, 2}}}}},
+		{CommitAuthor: "carol", ChurnFiles: []metrics.ChurnFile{{InteractiveChurn: map[string][]int{"alice": {1, 2, 3}, "bob": {1}}}}},
+		{CommitAuthor: "alice", ChurnFiles: []metrics.ChurnFile{{InteractiveChurn: map[string][]int{"erin": {1}}}}},
+		{CommitAuthor: "bob", ChurnFiles: []metrics.ChurnFile{{InteractiveChurn: map[string][]int{"erin": {1, 2}}}}},
+	}
+	g := metrics.NewInteractionGraph(churns)
+	want := map[string]map[string]string{
+		"alice": {"degree": "3", "in_strength": "5", "out_strength": "1", "betweenness": "1.5"},
+		"bob":   {"degree": "2", "in_strength": "1", "out_strength": "2", "betweenness": "0.5"},
+		"carol": {"degree": "3", "in_strength": "1", "out_strength": "4", "betweenness": "1"},
+		"dave":  {"degree": "2", "in_strength": "0", "out_strength": "3", "betweenness": "0"},
+		"erin":  {"degree": "2", "in_strength": "3", "out_strength": "0", "betweenness": "0"},
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := make(map[string]map[string]string)
+	node := regexp.MustCompile(`(?m)^  ("[^"]*") \[(.*)\];$`)
+	attr := regexp.MustCompile(`(\w+)=([^,]+)`)
+	for _, m := range node.FindAllStringSubmatch(buf.String(), -1) {
+		author, err := strconv.Unquote(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		dot[author] = make(map[string]string)
+		for _, a := range attr.FindAllStringSubmatch(m[2], -1) {
+			dot[author][a[1]] = a[2]
+		}
+	}
+	if !reflect.DeepEqual(dot, want) {
+		t.Errorf("DOT nodes %v, want %v", dot, want)
+	}
+
+	buf.Reset()
+	if err := g.WriteGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Nodes []struct {
+			ID   string `xml:"id,attr"`
+			Data []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"graph>node"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	graphML := make(map[string]map[string]string)
+	for _, n := range doc.Nodes {
+		graphML[n.ID] = make(map[string]string)
+		for _, d := range n.Data {
+			graphML[n.ID][d.Key] = d.Value
+		}
+	}
+	if !reflect.DeepEqual(graphML, want) {
+		t.Errorf("GraphML nodes %v, want %v", graphML, want)
+	}
+
+	buf.Reset()
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded metrics.InteractionGraph
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]map[string]string)
+	for _, n := range decoded.Nodes {
+		nodes[n.Author] = map[string]string{
+			"degree":       strconv.Itoa(n.Degree),
+			"in_strength":  strconv.Itoa(n.InStrength),
+			"out_strength": strconv.Itoa(n.OutStrength),
+			"betweenness":  strconv.FormatFloat(n.Betweenness, 'f', -1, 64),
+		}
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("JSON nodes %v, want %v", nodes, want)
+	}
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IssueExtractor extracts from commit messages the ids of the issues or pull
+// requests the commits are linked to.
+type IssueExtractor struct {
+	Name string
+	// Pattern matches the ids, which are its first group, or the whole match
+	// if it has no group. Numbers, and numbers prefixed by GH-, are
+	// normalized to #number.
+	Pattern *regexp.Regexp
+	// PullRequest tells that the ids are pull requests.
+	PullRequest bool
+	// Exclude, if not nil, matches the ids that are not extracted.
+	Exclude *regexp.Regexp
+}
+
+// DefaultIssueExtractors extract JIRA keys, GitHub references (#123 and
+// GH-123), the pull requests of squash (Title (#123)) and merge commits, and
+// the Fixes:, Closes: and Resolves: trailers.
+var DefaultIssueExtractors = []IssueExtractor{
+	{Name: "jira", Pattern: regexp.MustCompile(`\b([A-Z][A-Z0-9_]+-[1-9][0-9]*)\b`),
+		Exclude: regexp.MustCompile(`^(AES|CVE|HTTP|ISO|MD|RFC|RSA|SHA|SSL|TLS|UTF|UCS)-`)},
+	{Name: "github", Pattern: regexp.MustCompile(`(?:^|[^\w&/])((?:#|GH-)[0-9]+)\b`)},
+	{Name: "squash", Pattern: regexp.MustCompile(`\A[^\n]*\(#([0-9]+)\)[ \t]*(?:\n|\z)`), PullRequest: true},
+	{Name: "merge", Pattern: regexp.MustCompile(`\AMerge pull request #([0-9]+)`), PullRequest: true},
+	{Name: "trailers", Pattern: regexp.MustCompile(
+		`(?mi)^(?:fix(?:e[sd])?|close[sd]?|resolve[sd]?)[ \t]*:[ \t]*(?:\S*/issues/|#|GH-)?([A-Z][A-Z0-9_]+-[0-9]+|[0-9]+)\b`)},
+}
+
+// IssueExtractors extract the issues and pull requests of the churn records.
+var IssueExtractors = DefaultIssueExtractors
+
+// ExtractIssues returns the ids of the issues and of the pull requests the
+// message links to, in the order of the extractors. An id extracted as a pull
+// request is not an issue.
+func ExtractIssues(extractors []IssueExtractor, message string) (issues, pullRequests []string) {
+	seen := make(map[string]bool)
+	for _, pr := range []bool{true, false} {
+		for _, e := range extractors {
+			if e.PullRequest != pr {
+				continue
+			}
+			for _, match := range e.Pattern.FindAllStringSubmatch(message, -1) {
+				id := match[0]
+				if len(match) > 1 {
+					id = match[1]
+				}
+				if e.Exclude != nil && e.Exclude.MatchString(id) {
+					continue
+				}
+				if id = normalizeIssue(id); id == "" || seen[id] {
+					continue
+				}
+				seen[id] = true
+				if pr {
+					pullRequests = append(pullRequests, id)
+				} else {
+					issues = append(issues, id)
+				}
+			}
+		}
+	}
+	return issues, pullRequests
+}
+
+// normalizeIssue returns the id as #number if it is a number, or a number
+// prefixed by # or GH-.
+func normalizeIssue(id string) string {
+	id = strings.TrimSpace(id)
+	number := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(id), "GH-"), "#")
+	if number != "" && strings.Trim(number, "0123456789") == "" {
+		return "#" + number
+	}
+	return id
+}
+
+// IssueChurn is the churn of the commits linked to an issue or a pull
+// request.
+type IssueChurn struct {
+	ID               string
+	PullRequest      bool `json:",omitempty"`
+	Commits          int
+	SelfChurn        int
+	InteractiveChurn int
+}
+
+// AggregateIssues returns the churn of every issue and pull request the
+// churns are linked to, by decreasing total churn. A commit linked to several
+// ones counts for each.
+func AggregateIssues(churns []Churn) []IssueChurn {
+	byID := make(map[string]*IssueChurn)
+	var result []*IssueChurn
+	add := func(id string, pr bool, self, interactive int) {
+		key := id
+		if pr {
+			key = "pr " + id
+		}
+		ic, ok := byID[key]
+		if !ok {
+			ic = &IssueChurn{ID: id, PullRequest: pr}
+			byID[key] = ic
+			result = append(result, ic)
+		}
+		ic.Commits++
+		ic.SelfChurn += self
+		ic.InteractiveChurn += interactive
+	}
+	for _, churn := range churns {
+		self, interactive := 0, 0
+		for _, f := range churn.ChurnFiles {
+			self += len(f.SelfChurn)
+			for _, lines := range f.InteractiveChurn {
+				interactive += len(lines)
+			}
+		}
+		for _, id := range churn.Issues {
+			add(id, false, self, interactive)
+		}
+		for _, id := range churn.PullRequests {
+			add(id, true, self, interactive)
+		}
+	}
+
+	sorted := make([]IssueChurn, len(result))
+	for i, ic := range result {
+		sorted[i] = *ic
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SelfChurn+sorted[i].InteractiveChurn > sorted[j].SelfChurn+sorted[j].InteractiveChurn
+	})
+	return sorted
+}
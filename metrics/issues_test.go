@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+func TestExtractIssues(t *testing.T) {
+	tests := []struct {
+		message      string
+		issues       []string
+		pullRequests []string
+	}{
+		{"PROJ-42: fix the login", []string{"PROJ-42"}, nil},
+		{"Fix the login (PROJ-42, ABC_1-7)", []string{"PROJ-42", "ABC_1-7"}, nil},
+		{"Use SHA-256 and UTF-8 per RFC-7230", nil, nil},
+		{"PROJ-0 is not an issue", nil, nil},
+		{"fix #12 and #13, see #12", []string{"#12", "#13"}, nil},
+		{"Fix GH-7", []string{"#7"}, nil},
+		{"GH-7 is #7", []string{"#7"}, nil},
+		{"not an issue: a&#38;b or org/repo#5 or abc#6", nil, nil},
+		{"Add the login page (#123)\n\nCloses #45", []string{"#45"}, []string{"#123"}},
+		{"Add the login page (#123)", nil, []string{"#123"}},
+		{"Revert (#1) in the title (#2)", []string{"#1"}, []string{"#2"}},
+		{"Not squashed (#2) in the title", []string{"#2"}, nil},
+		{"Merge pull request #77 from alice/login\n\nAdd the login page for #45", []string{"#45"}, []string{"#77"}},
+		{"Add the login page\n\nFixes: https://github.com/org/repo/issues/88\nResolved: PROJ-9\ncloses: GH-10",
+			[]string{"PROJ-9", "#10", "#88"}, nil},
+		{"Merge branch 'main'", nil, nil},
+	}
+	for _, test := range tests {
+		issues, pullRequests := metrics.ExtractIssues(metrics.DefaultIssueExtractors, test.message)
+		if !reflect.DeepEqual(issues, test.issues) || !reflect.DeepEqual(pullRequests, test.pullRequests) {
+			t.Errorf("ExtractIssues(%q) = %q, %q, want %q, %q", test.message, issues, pullRequests, test.issues,
+				test.pullRequests)
+		}
+	}
+}
+
+func TestAggregateIssues(t *testing.T) {
+	churn := func(issues, pullRequests []string, self, interactive int) metrics.Churn {
+		f := metrics.ChurnFile{FileName: "a.txt", SelfChurn: make([]int, self),
+			InteractiveChurn: map[string][]int{"alice": make([]int, interactive)}}
+		return metrics.Churn{ChurnFiles: []metrics.ChurnFile{f, f}, Issues: issues, PullRequests: pullRequests}
+	}
+	churns := []metrics.Churn{
+		churn([]string{"#1"}, nil, 1, 0),
+		churn([]string{"#1", "PROJ-2"}, []string{"#3"}, 2, 1),
+		churn(nil, []string{"#1"}, 5, 0),
+		churn(nil, nil, 10, 10),
+	}
+	want := []metrics.IssueChurn{
+		{ID: "#1", PullRequest: true, Commits: 1, SelfChurn: 10},
+		{ID: "#1", Commits: 2, SelfChurn: 6, InteractiveChurn: 2},
+		{ID: "PROJ-2", Commits: 1, SelfChurn: 4, InteractiveChurn: 2},
+		{ID: "#3", PullRequest: true, Commits: 1, SelfChurn: 4, InteractiveChurn: 2},
+	}
+	if got := metrics.AggregateIssues(churns); !reflect.DeepEqual(got, want) {
+		t.Errorf("AggregateIssues = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile waits for the exclusive lock of the file name, creating it, and
+// returns the function releasing it. The lock is released if the process
+// dies.
+func lockFile(name string) (func(), error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ashishgalagali/go-git-churn/linediff"
+)
+
+// MoveOptions describes how the blocks of lines moved within a file, or
+// moved and copied across files, are detected, like git blame -M and -C.
+type MoveOptions struct {
+	// MinLines is the number of consecutive lines a block must have to be
+	// detected as moved. Moves within a file are not detected if it is 0,
+	// unless Copies is not 0.
+	MinLines int
+	// IgnoreWhitespace compares the lines ignoring their whitespace.
+	IgnoreWhitespace bool
+	// Copies is 1 to also detect the lines moved from the other files
+	// changed or deleted by the same commit, and 2 to also detect the lines
+	// of the files created by a commit copied from any file of its parent.
+	Copies int
+}
+
+// Moves describes how moved lines are detected.
+var Moves MoveOptions
+
+// DefaultMoveLines is the number of lines of a moved block when copies are
+// detected but MinLines is not set.
+const DefaultMoveLines = 3
+
+// minMoveChars and minCopyChars are the numbers of letters and digits of a
+// block moved within a file and across files, like the default scores of git
+// blame -M and -C, so that the lines of punctuation, e.g. closing braces, are
+// not moves.
+const (
+	minMoveChars = 20
+	minCopyChars = 40
+)
+
+// maxMoveCandidates is the number of source lines above which a line is not
+// used to start a moved block, to bound the cost of very common lines.
+const maxMoveCandidates = 64
+
+// minLines returns the number of lines of a moved block, 0 if moves are not
+// detected.
+func (o MoveOptions) minLines() int {
+	if o.MinLines <= 0 && o.Copies > 0 {
+		return DefaultMoveLines
+	}
+	return o.MinLines
+}
+
+// lineRef is the line n, from 0, of a file.
+type lineRef struct {
+	file string
+	n    int
+}
+
+// diffLine is a line of a file, deleted or inserted by a diff.
+type diffLine struct {
+	lineRef
+	text string
+}
+
+// fileDiff is the diff of a file against the parent revision, and its churn.
+type fileDiff struct {
+	churn *ChurnFile
+	hunks []linediff.Hunk
+}
+
+// moveSet are the lines of a revision moved or copied from its parent.
+type moveSet struct {
+	// origins maps the lines inserted by the revision that are moved or
+	// copied to the lines of the parent they come from
+	origins map[lineRef]lineRef
+	// moved are the lines of the parent deleted by the revision that are
+	// moved
+	moved map[lineRef]struct{}
+}
+
+// origin returns the line of the parent the line n of file comes from.
+func (m *moveSet) origin(file string, n int) (lineRef, bool) {
+	if m == nil {
+		return lineRef{}, false
+	}
+	from, ok := m.origins[lineRef{file, n}]
+	return from, ok
+}
+
+// isMoved tells if the line n of file in the parent is moved.
+func (m *moveSet) isMoved(file string, n int) bool {
+	if m == nil {
+		return false
+	}
+	_, ok := m.moved[lineRef{file, n}]
+	return ok
+}
+
+// findMoves returns the lines of the revision c moved or copied from its
+// parent p, given the diffs of its files and the files seen in c. It returns
+// nil if moves are not detected.
+func (b *blame) findMoves(c, p int, diffs []fileDiff, seen map[string]struct{}) *moveSet {
+	opts := Moves
+	if b.moves != nil {
+		opts = *b.moves
+	}
+	if opts.minLines() <= 0 || p == -1 {
+		return nil
+	}
+	var deleted, inserted []diffLine
+	created := make(map[string]bool)
+	for _, d := range diffs {
+		name := d.churn.FileName
+		created[name] = b.graph[name][p] == nil
+		sl, dl := 0, 0
+		for _, hunk := range d.hunks {
+			for _, line := range hunk.Lines {
+				switch hunk.Op {
+				case linediff.Equal:
+					sl++
+					dl++
+				case linediff.Insert:
+					inserted = append(inserted, diffLine{lineRef{name, dl}, line})
+					dl++
+				case linediff.Delete:
+					deleted = append(deleted, diffLine{lineRef{name, sl}, line})
+					sl++
+				}
+			}
+		}
+	}
+	if opts.Copies > 0 {
+		// all the lines of the files deleted by c are deleted
+		for _, name := range b.parentFiles(p) {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			for n, line := range linediff.Split(b.data[name][p]) {
+				deleted = append(deleted, diffLine{lineRef{name, n}, line})
+			}
+		}
+	}
+
+	set := &moveSet{origins: make(map[lineRef]lineRef), moved: make(map[lineRef]struct{})}
+	for to, from := range opts.moves(deleted, inserted, opts.Copies > 0, false) {
+		set.origins[to] = from
+		set.moved[from] = struct{}{}
+	}
+	if opts.Copies < 2 {
+		return set
+	}
+	var copied []diffLine
+	for _, l := range inserted {
+		if _, ok := set.origins[l.lineRef]; !ok && created[l.file] {
+			copied = append(copied, l)
+		}
+	}
+	if len(copied) < opts.minLines() {
+		return set
+	}
+	var present []diffLine
+	for _, name := range b.parentFiles(p) {
+		for n, line := range linediff.Split(b.data[name][p]) {
+			present = append(present, diffLine{lineRef{name, n}, line})
+		}
+	}
+	for to, from := range opts.moves(present, copied, true, true) {
+		set.origins[to] = from
+	}
+	return set
+}
+
+// parentFiles returns the sorted names of the files of the revision p whose
+// lines are known.
+func (b *blame) parentFiles(p int) []string {
+	var names []string
+	for name, revs := range b.graph {
+		if len(revs[p]) != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// moves returns the lines of to that are blocks of lines of from, as a map
+// from a line of to to the line of from it comes from. Both slices are sorted
+// by file and line, and the lines of a block are consecutive in their files
+// on both sides. Blocks only match across files if crossFile is set, and the
+// lines of from can be the source of several blocks if reuse is set.
+func (o MoveOptions) moves(from, to []diffLine, crossFile, reuse bool) map[lineRef]lineRef {
+	minLines := o.minLines()
+	if minLines <= 0 || len(from) < minLines || len(to) < minLines {
+		return nil
+	}
+	fKeys := make([]string, len(from))
+	candidates := make(map[string][]int)
+	for i, l := range from {
+		fKeys[i] = o.key(l.text)
+		candidates[fKeys[i]] = append(candidates[fKeys[i]], i)
+	}
+	tKeys := make([]string, len(to))
+	for i, l := range to {
+		tKeys[i] = o.key(l.text)
+	}
+
+	result := make(map[lineRef]lineRef)
+	used := make([]bool, len(from))
+	for i := 0; i < len(to); {
+		best, bestLen := -1, 0
+		if c := candidates[tKeys[i]]; len(c) <= maxMoveCandidates {
+			for _, f := range c {
+				if !crossFile && from[f].file != to[i].file {
+					continue
+				}
+				n := 0
+				for i+n < len(to) && f+n < len(from) && (reuse || !used[f+n]) && tKeys[i+n] == fKeys[f+n] &&
+					(n == 0 || consecutive(to[i], to[i+n], n) && consecutive(from[f], from[f+n], n)) {
+					n++
+				}
+				if n > bestLen {
+					best, bestLen = f, n
+				}
+			}
+		}
+		if bestLen < minLines || !enoughChars(tKeys[i:i+bestLen], from[best].file != to[i].file) {
+			i++
+			continue
+		}
+		for n := 0; n < bestLen; n++ {
+			result[to[i+n].lineRef] = from[best+n].lineRef
+			used[best+n] = true
+		}
+		i += bestLen
+	}
+	return result
+}
+
+// consecutive tells if the line l is n lines after the line first.
+func consecutive(first, l diffLine, n int) bool {
+	return l.file == first.file && l.n == first.n+n
+}
+
+// key returns the text a line is compared by.
+func (o MoveOptions) key(line string) string {
+	if !o.IgnoreWhitespace {
+		return strings.TrimSuffix(line, "\n")
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+// enoughChars tells if the lines of a block have enough letters and digits
+// to be a move, or a move across files if crossFile is set.
+func enoughChars(lines []string, crossFile bool) bool {
+	min := minMoveChars
+	if crossFile {
+		min = minCopyChars
+	}
+	n := 0
+	for _, l := range lines {
+		for _, r := range l {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				n++
+			}
+		}
+	}
+	return n >= min
+}
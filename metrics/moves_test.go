@@ -0,0 +1,134 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// withMoves sets metrics.Moves for the test.
+func withMoves(t *testing.T, moves metrics.MoveOptions) {
+	saved := metrics.Moves
+	metrics.Moves = moves
+	t.Cleanup(func() { metrics.Moves = saved })
+}
+
+// block returns a block of 4 indented lines, different from the ones of the
+// other names.
+func block(name string) string {
+	var b strings.Builder
+	for i := 1; i <= 4; i++ {
+		fmt.Fprintf(&b, "\t%s := compute(%d, %sOffset)\n", name, i, name)
+	}
+	return b.String()
+}
+
+// lastChurnFile returns the churn of the file name by c, the last commit of
+// its history.
+func lastChurnFile(t *testing.T, c *object.Commit, name string) metrics.ChurnFile {
+	t.Helper()
+	churns, err := metrics.Churns(context.Background(), c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range churns[len(churns)-1].ChurnFiles {
+		if f.FileName == name {
+			return f
+		}
+	}
+	return metrics.ChurnFile{FileName: name}
+}
+
+// The blocks moved within a file keep their origin and are not churn, unless
+// they are too short or only punctuation.
+func TestMovesWithinFile(t *testing.T) {
+	alpha, beta := block("alpha"), block("beta")
+	braces := "}\n}\n}\n"
+	tests := []struct {
+		name       string
+		moves      metrics.MoveOptions
+		src, dst   string
+		moved      bool
+		aliceLines int
+	}{
+		{"reordered", metrics.MoveOptions{MinLines: 3}, alpha + beta, beta + alpha, true, 8},
+		{"not detected", metrics.MoveOptions{}, alpha + beta, beta + alpha, false, 4},
+		{"too short", metrics.MoveOptions{MinLines: 5}, alpha + beta, beta + alpha, false, 4},
+		{"braces", metrics.MoveOptions{MinLines: 3}, braces + lines("x", 10), lines("x", 10) + braces, false, 10},
+		{"reindented", metrics.MoveOptions{MinLines: 3}, alpha + beta,
+			beta + strings.Replace(alpha, "\t", "    ", -1), false, 4},
+		{"reindented ignoring whitespace", metrics.MoveOptions{MinLines: 3, IgnoreWhitespace: true}, alpha + beta,
+			beta + strings.Replace(alpha, "\t", "    ", -1), true, 8},
+	}
+	for _, test := range tests {
+		withMoves(t, test.moves)
+		tr := newTestRepo(t)
+		tr.commit("alice", map[string]string{"f.go": test.src})
+		head := tr.commit("bob", map[string]string{"f.go": test.dst})
+
+		f := lastChurnFile(t, head, "f.go")
+		if test.moved && (len(f.Moved) != 4 || len(f.InteractiveChurn) != 0) {
+			t.Errorf("%s: churn %+v, want the block moved", test.name, f)
+		}
+		if !test.moved && (len(f.Moved) != 0 || len(f.InteractiveChurn["alice"]) < 3) {
+			t.Errorf("%s: churn %+v, want the lines of alice deleted", test.name, f)
+		}
+		if got := ownersOf(t, head)["f.go"]["alice"]; got != test.aliceLines {
+			t.Errorf("%s: alice owns %d lines, want %d", test.name, got, test.aliceLines)
+		}
+	}
+}
+
+// The files split from another one keep the origin of its lines, which are
+// moved rather than churn.
+func TestMovesAcrossFiles(t *testing.T) {
+	withMoves(t, metrics.MoveOptions{Copies: 1})
+	tr := newTestRepo(t)
+	alpha, beta, gamma := block("alpha"), block("beta"), block("gamma")
+	tr.commit("alice", map[string]string{"all.go": alpha + beta})
+	tr.commit("carol", map[string]string{"all.go": alpha + beta + gamma})
+	head := tr.commit("bob", map[string]string{"all.go": alpha, "beta.go": beta, "gamma.go": "// gamma\n" + gamma})
+
+	want := map[string]map[string]int{
+		"all.go":   {"alice": 4},
+		"beta.go":  {"alice": 4},
+		"gamma.go": {"bob": 1, "carol": 4},
+	}
+	if got := ownersOf(t, head); !reflect.DeepEqual(got, want) {
+		t.Errorf("owners %v, want %v", got, want)
+	}
+	if f := lastChurnFile(t, head, "all.go"); len(f.Moved) != 8 || len(f.InteractiveChurn) != 0 {
+		t.Errorf("churn of all.go %+v, want 8 lines moved", f)
+	}
+
+	// without -C the new files are bob's
+	withMoves(t, metrics.MoveOptions{})
+	if got := ownersOf(t, head)["beta.go"]; !reflect.DeepEqual(got, map[string]int{"bob": 4}) {
+		t.Errorf("owners of beta.go without copies %v, want bob", got)
+	}
+}
+
+// With -C -C the lines of a new file copied from a file the commit does not
+// change keep their origin.
+func TestCopiesFromUnchangedFile(t *testing.T) {
+	tr := newTestRepo(t)
+	alpha, beta := block("alpha"), block("beta")
+	tr.commit("alice", map[string]string{"lib.go": alpha + beta, "notes.txt": lines("n", 2)})
+	head := tr.commit("bob", map[string]string{"copy.go": beta, "notes.txt": lines("n", 3)})
+
+	for copies, owner := range map[int]string{1: "bob", 2: "alice"} {
+		withMoves(t, metrics.MoveOptions{Copies: copies})
+		owners := ownersOf(t, head)
+		if got := owners["copy.go"]; !reflect.DeepEqual(got, map[string]int{owner: 4}) {
+			t.Errorf("copies %d: owners of copy.go %v, want %s", copies, got, owner)
+		}
+		if got := owners["lib.go"]; !reflect.DeepEqual(got, map[string]int{"alice": 8}) {
+			t.Errorf("copies %d: owners of lib.go %v, want alice", copies, got)
+		}
+	}
+}
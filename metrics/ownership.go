@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// MinorContributorThreshold is the share of lines under which an author is
+// considered a minor contributor of a file or directory, as defined in Bird,
+// et al. "Don't Touch My Code! Examining the Effects of Ownership on Software
+// Quality", in proceedings of ESEC/FSE 2011.
+const MinorContributorThreshold = 0.05
+
+// OwnershipResult represents the result of an Ownership operation.
+type OwnershipResult struct {
+	// Rev (Revision) is the hash of the commit the ownership was computed at.
+	Rev plumbing.Hash
+	// Files holds the ownership of every file, sorted by path.
+	Files []Ownership
+	// Directories holds the ownership of every directory, sorted by path.
+	// The root of the repository is reported as ".".
+	Directories []Ownership
+}
+
+// Ownership describes how the surviving lines of a file or a directory are
+// spread among their authors.
+type Ownership struct {
+	Path       string
+	TotalLines int
+	// Authors is sorted by number of lines, the top owner first.
+	Authors []AuthorShare
+	// TopOwner is the author of most of the lines and TopOwnerShare the
+	// share of lines they wrote.
+	TopOwner      string
+	TopOwnerShare float64
+	// MinorContributors are the authors below MinorContributorThreshold.
+	MinorContributors []string
+}
+
+// AuthorShare is the number of surviving lines of an author.
+type AuthorShare struct {
+	Author string
+	Lines  int
+	Share  float64
+}
+
+// ComputeOwnership returns the share of surviving lines per author of every
+// file and directory at commit c. If prefix is not empty only the files below
+// it are reported.
+func ComputeOwnership(ctx context.Context, c *object.Commit, prefix string) (*OwnershipResult, error) {
+	b := new(blame)
+	b.fRev = c
+	if err := b.fillRevs(ctx); err != nil {
+		return nil, err
+	}
+	if err := b.fillGraphAndData(ctx); err != nil {
+		return nil, err
+	}
+
+	lines, err := b.finalAuthors(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOwnershipResult(c.Hash, lines), nil
+}
+
+// finalAuthors returns the number of lines per author of every file in the
+// final revision whose path starts with prefix.
+func (b *blame) finalAuthors(prefix string) (map[string]map[string]int, error) {
+	i, ok := b.commitIndexMap[b.fRev.Hash.String()]
+	if !ok {
+		return nil, &Error{Kind: ErrRevisionNotFound, Commit: b.fRev.Hash.String(),
+			Err: errors.New("not in its own history")}
+	}
+
+	result := make(map[string]map[string]int)
+	iter, err := b.revs[i].Files()
+	if err != nil {
+		return nil, wrapError(err, b.fRev.Hash.String(), "")
+	}
+	defer iter.Close()
+	for {
+		file, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, wrapError(err, b.fRev.Hash.String(), "")
+		}
+		if !hasPathPrefix(file.Name, prefix) {
+			continue
+		}
+		authors := make(map[string]int)
+		for _, origin := range b.graph[file.Name][i] {
+			if origin != nil {
+				authors[origin.Author.Email]++
+			}
+		}
+		result[file.Name] = authors
+	}
+	return result, nil
+}
+
+func newOwnershipResult(rev plumbing.Hash, files map[string]map[string]int) *OwnershipResult {
+	dirs := make(map[string]map[string]int)
+	for name, authors := range files {
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			if _, ok := dirs[dir]; !ok {
+				dirs[dir] = make(map[string]int)
+			}
+			for author, n := range authors {
+				dirs[dir][author] += n
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+
+	return &OwnershipResult{
+		Rev:         rev,
+		Files:       newOwnerships(files),
+		Directories: newOwnerships(dirs),
+	}
+}
+
+func newOwnerships(lines map[string]map[string]int) []Ownership {
+	result := make([]Ownership, 0, len(lines))
+	for name, authors := range lines {
+		result = append(result, newOwnership(name, authors))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+func newOwnership(name string, authors map[string]int) Ownership {
+	o := Ownership{Path: name}
+	for _, n := range authors {
+		o.TotalLines += n
+	}
+	for author, n := range authors {
+		share := 0.0
+		if o.TotalLines != 0 {
+			share = float64(n) / float64(o.TotalLines)
+		}
+		o.Authors = append(o.Authors, AuthorShare{Author: author, Lines: n, Share: share})
+	}
+	sort.Slice(o.Authors, func(i, j int) bool {
+		if o.Authors[i].Lines != o.Authors[j].Lines {
+			return o.Authors[i].Lines > o.Authors[j].Lines
+		}
+		return o.Authors[i].Author < o.Authors[j].Author
+	})
+
+	if len(o.Authors) != 0 {
+		o.TopOwner = o.Authors[0].Author
+		o.TopOwnerShare = o.Authors[0].Share
+	}
+	for _, a := range o.Authors {
+		if a.Share < MinorContributorThreshold {
+			o.MinorContributors = append(o.MinorContributors, a.Author)
+		}
+	}
+	return o
+}
+
+// hasPathPrefix tells if name is prefix or is below the directory prefix.
+func hasPathPrefix(name, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || prefix == "." || name == prefix {
+		return true
+	}
+	return strings.HasPrefix(name, prefix+"/")
+}
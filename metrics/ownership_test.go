@@ -0,0 +1,86 @@
+package metrics_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ownersOf returns the lines of every author of every file at c.
+func ownersOf(t *testing.T, c *object.Commit) map[string]map[string]int {
+	t.Helper()
+	result, err := metrics.ComputeOwnership(context.Background(), c, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	owners := make(map[string]map[string]int)
+	for _, f := range result.Files {
+		owners[f.Path] = make(map[string]int)
+		for _, a := range f.Authors {
+			owners[f.Path][a.Author] = a.Lines
+		}
+	}
+	return owners
+}
+
+// The lines of a renamed file keep their authors.
+func TestOwnershipOfRenamedFile(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("alice", map[string]string{"a.txt": lines("a", 10)})
+	head := tr.commit("bob", map[string]string{
+		"a.txt": "",
+		"b.txt": strings.Replace(lines("a", 10), "a5\n", "b5\n", 1),
+	})
+
+	want := map[string]map[string]int{"b.txt": {"alice": 9, "bob": 1}}
+	if got := ownersOf(t, head); !reflect.DeepEqual(got, want) {
+		t.Errorf("owners %v, want %v", got, want)
+	}
+	churns, err := metrics.Churns(context.Background(), head, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	churn := churns[len(churns)-1]
+	if churn.Stats.FilesRenamed != 1 || churn.Stats.Insertions != 1 || churn.Stats.Deletions != 1 {
+		t.Errorf("stats %+v, want 1 file renamed, 1 insertion and 1 deletion", churn.Stats)
+	}
+	wantFiles := []metrics.ChurnFile{{FileName: "b.txt", InteractiveChurn: map[string][]int{"alice": {5}}}}
+	if !reflect.DeepEqual(churn.ChurnFiles, wantFiles) {
+		t.Errorf("churn files %+v, want %+v", churn.ChurnFiles, wantFiles)
+	}
+}
+
+// The lines of a merge keep the authors of the branch they come from, and the
+// merge itself is no churn.
+func TestOwnershipOfMerge(t *testing.T) {
+	tr := newTestRepo(t)
+	base := lines("a", 10)
+	tr.commit("alice", map[string]string{"f.txt": base})
+	tr.checkout("topic", true)
+	topic := tr.commit("bob", map[string]string{"f.txt": strings.Replace(base, "a3\n", "b3\n", 1)})
+	tr.checkout("master", false)
+	tr.commit("carol", map[string]string{"f.txt": strings.Replace(base, "a8\n", "c8\n", 1)})
+	merged := strings.Replace(strings.Replace(base, "a3\n", "b3\n", 1), "a8\n", "c8\n", 1)
+	tr.write(map[string]string{"f.txt": merged})
+	head := tr.commitIndex("dave", "Merge branch 'topic'", topic.Hash)
+
+	want := map[string]map[string]int{"f.txt": {"alice": 8, "bob": 1, "carol": 1}}
+	if got := ownersOf(t, head); !reflect.DeepEqual(got, want) {
+		t.Errorf("owners %v, want %v", got, want)
+	}
+	churns, err := metrics.Churns(context.Background(), head, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	churn := churns[len(churns)-1]
+	if churn.CommitID != head.Hash.String() {
+		t.Fatalf("last churn %s, want the merge %s", churn.CommitID, head.Hash)
+	}
+	if len(churn.ChurnFiles) != 0 || churn.Stats.InteractiveChurn != 0 || churn.Stats.SelfChurn != 0 {
+		t.Errorf("merge churn %+v, want none", churn)
+	}
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// The phases of a run reported in the progress events.
+const (
+	PhaseCloning    = "cloning"
+	PhaseWalking    = "walking history"
+	PhaseProcessing = "processing revisions"
+)
+
+// walkProgressEvery is the number of commits walked between two progress
+// events while walking the history.
+const walkProgressEvery = 100
+
+// ProgressEvent reports the progress of a phase of a run.
+type ProgressEvent struct {
+	Phase string
+	// Done is the number of commits processed out of Total, which is 0 while
+	// it is unknown.
+	Done  int
+	Total int
+	// Files is the number of files processed, FilesPerSecond their rate.
+	Files          int
+	FilesPerSecond float64
+	Elapsed        time.Duration
+	// ETA is the estimated time left, 0 if it is unknown.
+	ETA time.Duration
+	// Message is the last progress message of the remote while cloning.
+	Message string `json:",omitempty"`
+}
+
+// ProgressFunc receives the progress events of a run.
+type ProgressFunc func(ProgressEvent)
+
+type progressKey struct{}
+
+// WithProgress returns a context that reports the progress of the runs it is
+// passed to to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progress measures the progress of a phase and reports it to the
+// ProgressFunc of the context, if any.
+type progress struct {
+	fn    ProgressFunc
+	phase string
+	start time.Time
+	total int
+	files int
+}
+
+func newProgress(ctx context.Context, phase string, total int) *progress {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	p := &progress{fn: fn, phase: phase, start: time.Now(), total: total}
+	p.report(0, "")
+	return p
+}
+
+// addFiles records that n more files were processed.
+func (p *progress) addFiles(n int) {
+	p.files += n
+}
+
+func (p *progress) report(done int, message string) {
+	if p.fn == nil {
+		return
+	}
+	ev := ProgressEvent{
+		Phase:   p.phase,
+		Done:    done,
+		Total:   p.total,
+		Files:   p.files,
+		Elapsed: time.Since(p.start),
+		Message: message,
+	}
+	if seconds := ev.Elapsed.Seconds(); seconds > 0 {
+		ev.FilesPerSecond = float64(p.files) / seconds
+	}
+	if done > 0 && p.total > done {
+		ev.ETA = time.Duration(float64(ev.Elapsed) / float64(done) * float64(p.total-done))
+	}
+	p.fn(ev)
+}
+
+// writer returns a writer for the sideband progress of the remote, that
+// reports every message it receives.
+func (p *progress) writer() io.Writer {
+	if p.fn == nil {
+		return nil
+	}
+	return &progressWriter{p: p}
+}
+
+type progressWriter struct {
+	p    *progress
+	line []byte
+}
+
+func (w *progressWriter) Write(data []byte) (int, error) {
+	for _, c := range data {
+		if c != '\r' && c != '\n' {
+			w.line = append(w.line, c)
+			continue
+		}
+		if line := bytes.TrimSpace(w.line); len(line) != 0 {
+			w.p.report(0, string(line))
+		}
+		w.line = w.line[:0]
+	}
+	return len(data), nil
+}
@@ -0,0 +1,65 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// The history is walked then its revisions are processed, and the number of
+// revisions done only grows up to their total.
+func TestProgress(t *testing.T) {
+	tr := newTestRepo(t)
+	head := tr.commit("alice", map[string]string{"f1.txt": "1\n"})
+	for i := 2; i <= 5; i++ {
+		head = tr.commit("alice", map[string]string{fmt.Sprintf("f%d.txt", i): fmt.Sprintf("%d\n", i)})
+	}
+
+	var events []metrics.ProgressEvent
+	ctx := metrics.WithProgress(context.Background(), func(ev metrics.ProgressEvent) {
+		events = append(events, ev)
+	})
+	if _, err := metrics.Churns(ctx, head, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var phases []string
+	done := 0
+	for _, ev := range events {
+		if len(phases) == 0 || phases[len(phases)-1] != ev.Phase {
+			phases = append(phases, ev.Phase)
+			done = 0
+		}
+		if ev.Done < done || ev.Done > 5 {
+			t.Errorf("%s: done %d after %d, want it to grow up to 5", ev.Phase, ev.Done, done)
+		}
+		done = ev.Done
+		switch ev.Phase {
+		case metrics.PhaseWalking:
+			if ev.Total != 0 || ev.ETA != 0 {
+				t.Errorf("walking: total %d and ETA %v, want them unknown", ev.Total, ev.ETA)
+			}
+		case metrics.PhaseProcessing:
+			if ev.Total != 5 {
+				t.Errorf("processing: total %d, want 5", ev.Total)
+			}
+			var eta time.Duration
+			if ev.Done > 0 && ev.Done < ev.Total {
+				eta = time.Duration(float64(ev.Elapsed) / float64(ev.Done) * float64(ev.Total-ev.Done))
+			}
+			if ev.ETA != eta {
+				t.Errorf("processing: ETA %v after %v at %d of %d, want %v", ev.ETA, ev.Elapsed, ev.Done, ev.Total, eta)
+			}
+		}
+	}
+	if len(phases) != 2 || phases[0] != metrics.PhaseWalking || phases[1] != metrics.PhaseProcessing {
+		t.Fatalf("phases %q, want %q then %q", phases, metrics.PhaseWalking, metrics.PhaseProcessing)
+	}
+	last := events[len(events)-1]
+	if last.Done != 5 || last.Files != 15 {
+		t.Errorf("last event done %d with %d files, want 5 with 15", last.Done, last.Files)
+	}
+}
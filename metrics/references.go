@@ -1,18 +1,17 @@
 package metrics
 
 import (
+	"context"
 	"io"
 	"sort"
 
+	"github.com/ashishgalagali/go-git-churn/linediff"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/utils/diff"
-
-	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
 // References returns a slice of Commits for the file at "path", starting from
-// the commit provided that contains the file from the provided path. The last
+// the commits provided that contain the file from the provided path. The last
 // commit into the returned slice is the commit where the file was created.
 // If the provided commit does not contains the specified path, a nil slice is
 // returned. The commits are sorted in commit order, newer to older.
@@ -24,12 +23,17 @@ import (
 // - Cherry-picks are not detected unless there are no commits between them and
 // therefore can appear repeated in the list. (see git path-id for hints on how
 // to fix this).
-func references(c *object.Commit, path string) ([]*object.Commit, error) {
+func references(ctx context.Context, tips []*object.Commit, path string) ([]*object.Commit, error) {
 	var result []*object.Commit
 	seen := make(map[plumbing.Hash]struct{})
-	if err := walkGraph(&result, &seen, c, path); err != nil {
-		return nil, err
+	p := newProgress(ctx, PhaseWalking, 0)
+	for _, c := range tips {
+		// the history shared with the previous tips is already walked
+		if err := walkGraph(ctx, p, &result, &seen, c, path); err != nil {
+			return nil, err
+		}
 	}
+	p.report(len(seen), "")
 
 	// TODO result should be returned without ordering
 	sortCommits(result)
@@ -38,7 +42,7 @@ func references(c *object.Commit, path string) ([]*object.Commit, error) {
 	if path == "" {
 		return result, nil
 	}
-	return removeComp(path, result, equivalent)
+	return removeComp(ctx, path, result, equivalent)
 }
 
 type commitSorterer struct {
@@ -67,7 +71,10 @@ func sortCommits(l []*object.Commit) {
 
 // Recursive traversal of the commit graph, generating a linear history of the
 // path.
-func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, current *object.Commit, path string) error {
+func walkGraph(ctx context.Context, p *progress, result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, current *object.Commit, path string) error {
+	if err := ctx.Err(); err != nil {
+		return wrapError(err, "", "")
+	}
 	// check and update seen
 	if _, ok := (*seen)[current.Hash]; ok {
 		return nil
@@ -80,6 +87,9 @@ func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, curre
 	//	return nil
 	//}
 	(*seen)[current.Hash] = struct{}{}
+	if len(*seen)%walkProgressEvery == 0 {
+		p.report(len(*seen), "")
+	}
 
 	//TODO: look into this when considering all the files for a commit
 	// if the path is not in the current commit, stop searching.
@@ -93,7 +103,7 @@ func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, curre
 	// contain the path.
 	parents, err := parentsContainingPath(path, current)
 	if err != nil {
-		return err
+		return wrapError(err, current.Hash.String(), path)
 	}
 	switch len(parents) {
 	// if the path is not found in any of its parents, the path was
@@ -108,7 +118,7 @@ func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, curre
 		if path != "" {
 			different, err := differentContents(path, current, parents)
 			if err != nil {
-				return err
+				return wrapError(err, current.Hash.String(), path)
 			}
 			if len(different) == 1 {
 				*result = append(*result, current)
@@ -117,13 +127,13 @@ func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, curre
 			*result = append(*result, current)
 		}
 		// in any case, walk the parent
-		return walkGraph(result, seen, parents[0], path)
+		return walkGraph(ctx, p, result, seen, parents[0], path)
 	default: // more than one parent contains the path
 		// TODO: detect merges that had a conflict, because they must be
 		// included in the result here.
 		*result = append(*result, current)
-		for _, p := range parents {
-			err := walkGraph(result, seen, p, path)
+		for _, parent := range parents {
+			err := walkGraph(ctx, p, result, seen, parent, path)
 			if err != nil {
 				return err
 			}
@@ -189,16 +199,19 @@ type contentsComparatorFn func(path string, a, b *object.Commit) (bool, error)
 // Returns a new slice of commits, with duplicates removed.  Expects a
 // sorted commit list.  Duplication is defined according to "comp".  It
 // will always keep the first commit of a series of duplicated commits.
-func removeComp(path string, cs []*object.Commit, comp contentsComparatorFn) ([]*object.Commit, error) {
+func removeComp(ctx context.Context, path string, cs []*object.Commit, comp contentsComparatorFn) ([]*object.Commit, error) {
 	result := make([]*object.Commit, 0, len(cs))
 	if len(cs) == 0 {
 		return result, nil
 	}
 	result = append(result, cs[0])
 	for i := 1; i < len(cs); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, wrapError(err, "", "")
+		}
 		equals, err := comp(path, cs[i], cs[i-1])
 		if err != nil {
-			return nil, err
+			return nil, wrapError(err, cs[i].Hash.String(), path)
 		}
 		if !equals {
 			result = append(result, cs[i])
@@ -230,7 +243,7 @@ func equivalent(path string, a, b *object.Commit) (bool, error) {
 	return sameDiffs(diffsA, diffsB), nil
 }
 
-func patch(c *object.Commit, path string) ([]diffmatchpatch.Diff, error) {
+func patch(c *object.Commit, path string) ([]linediff.Hunk, error) {
 	// get contents of the file in the commit
 	file, err := c.File(path)
 	if err != nil {
@@ -259,10 +272,10 @@ func patch(c *object.Commit, path string) ([]diffmatchpatch.Diff, error) {
 	}
 
 	// compare the contents of parent and child
-	return diff.Do(content, contentParent), nil
+	return DiffAlgorithm.Do(content, contentParent), nil
 }
 
-func sameDiffs(a, b []diffmatchpatch.Diff) bool {
+func sameDiffs(a, b []linediff.Hunk) bool {
 	if len(a) != len(b) {
 		return false
 	}
@@ -274,16 +287,17 @@ func sameDiffs(a, b []diffmatchpatch.Diff) bool {
 	return true
 }
 
-func sameDiff(a, b diffmatchpatch.Diff) bool {
-	if a.Type != b.Type {
+func sameDiff(a, b linediff.Hunk) bool {
+	if a.Op != b.Op || len(a.Lines) != len(b.Lines) {
 		return false
 	}
-	switch a.Type {
-	case 0:
-		return countLines(a.Text) == countLines(b.Text)
-	case 1, -1:
-		return a.Text == b.Text
-	default:
-		panic("unreachable")
+	if a.Op == linediff.Equal {
+		return true
 	}
+	for i := range a.Lines {
+		if a.Lines[i] != b.Lines[i] {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// Tip is a commit the history is walked from, and the ref naming it.
+type Tip struct {
+	Ref    string
+	Commit *object.Commit
+}
+
+// TipOptions selects the tips of the history to walk.
+type TipOptions struct {
+	// Refs are branches, tags, remote branches or any revision understood
+	// by ResolveRevision. Branches are also looked up among the remote
+	// branches of origin.
+	Refs []string
+	// Branches adds the local branches and the remote branches that have no
+	// local branch of the same name.
+	Branches bool
+	// Tags adds the tags pointing to commits.
+	Tags bool
+	// All adds all the refs, HEAD aside.
+	All bool
+}
+
+// ResolveTips returns the tips selected by opts in r, ordered as the refs are
+// given and then by name. Refs pointing to the same commit are kept, so that
+// the churn lists all of them.
+func ResolveTips(r *git.Repository, opts TipOptions) ([]Tip, error) {
+	var tips []Tip
+	seen := make(map[string]bool)
+	for _, ref := range opts.Refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		c, err := resolveCommit(r, ref)
+		if err != nil {
+			// the branches of a clone are remote branches of origin
+			if c, _ = resolveCommit(r, "refs/remotes/origin/"+ref); c == nil {
+				return nil, err
+			}
+		}
+		tips = append(tips, Tip{Ref: ref, Commit: c})
+	}
+	if !opts.Branches && !opts.Tags && !opts.All {
+		return tips, nil
+	}
+
+	iter, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+	var refs []*plumbing.Reference
+	local := make(map[string]bool)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		switch {
+		case name == plumbing.HEAD, strings.HasSuffix(name.String(), "/HEAD"):
+			return nil
+		case name.IsBranch():
+			local[name.Short()] = true
+			if !opts.Branches && !opts.All {
+				return nil
+			}
+		case name.IsRemote():
+			if !opts.Branches && !opts.All {
+				return nil
+			}
+		case name.IsTag():
+			if !opts.Tags && !opts.All {
+				return nil
+			}
+		default:
+			if !opts.All {
+				return nil
+			}
+		}
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name() < refs[j].Name()
+	})
+
+	for _, ref := range refs {
+		name := ref.Name()
+		if name.IsRemote() {
+			short := name.Short()
+			if i := strings.Index(short, "/"); i != -1 && local[short[i+1:]] {
+				continue
+			}
+		}
+		if seen[name.Short()] {
+			continue
+		}
+		seen[name.Short()] = true
+		c, err := refCommit(r, ref)
+		if err != nil {
+			return nil, wrapError(err, name.String(), "")
+		}
+		if c != nil {
+			tips = append(tips, Tip{Ref: name.Short(), Commit: c})
+		}
+	}
+	return tips, nil
+}
+
+// refCommit returns the commit ref points to, peeling annotated tags, or nil
+// if it does not point to a commit.
+func refCommit(r *git.Repository, ref *plumbing.Reference) (*object.Commit, error) {
+	ref, err := storer.ResolveReference(r.Storer, ref.Name())
+	if err != nil {
+		return nil, err
+	}
+	obj, err := r.Object(plumbing.AnyObject, ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch o := obj.(type) {
+		case *object.Commit:
+			return o, nil
+		case *object.Tag:
+			if obj, err = o.Object(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// reachableRefs returns the refs of the tips every commit of their history is
+// reachable from. The refs are propagated from the children to the parents,
+// so that every commit is visited once whatever the number of tips.
+func reachableRefs(ctx context.Context, tips []Tip) (map[plumbing.Hash][]string, error) {
+	words := (len(tips) + 63) / 64
+	bits := make(map[plumbing.Hash][]uint64)
+	children := make(map[plumbing.Hash]int)
+	var commits []*object.Commit
+	seen := make(map[plumbing.Hash]bool)
+	for i, tip := range tips {
+		set, ok := bits[tip.Commit.Hash]
+		if !ok {
+			set = make([]uint64, words)
+			bits[tip.Commit.Hash] = set
+		}
+		set[i/64] |= 1 << uint(i%64)
+
+		iter := object.NewCommitPreorderIter(tip.Commit, seen, nil)
+		err := iter.ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			commits = append(commits, c)
+			for _, p := range c.ParentHashes {
+				children[p]++
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			return nil, wrapError(err, tip.Commit.Hash.String(), "")
+		}
+	}
+
+	// the commits without children left are visited before their parents
+	byHash := make(map[plumbing.Hash]*object.Commit, len(commits))
+	var queue []*object.Commit
+	for _, c := range commits {
+		byHash[c.Hash] = c
+		if children[c.Hash] == 0 {
+			queue = append(queue, c)
+		}
+	}
+	result := make(map[plumbing.Hash][]string, len(commits))
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		set := bits[c.Hash]
+		delete(bits, c.Hash)
+		for i, tip := range tips {
+			if set[i/64]&(1<<uint(i%64)) != 0 {
+				result[c.Hash] = append(result[c.Hash], tip.Ref)
+			}
+		}
+		for _, p := range c.ParentHashes {
+			parent, ok := byHash[p]
+			if !ok {
+				continue
+			}
+			pset, ok := bits[p]
+			if !ok {
+				pset = make([]uint64, words)
+				bits[p] = pset
+			}
+			for w := range set {
+				pset[w] |= set[w]
+			}
+			if children[p]--; children[p] == 0 {
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// epoch is the date of the start of the histories.
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testRepo builds a history in memory, one commit an hour.
+type testRepo struct {
+	t    testing.TB
+	r    *git.Repository
+	w    *git.Worktree
+	when time.Time
+}
+
+func newTestRepo(t testing.TB) *testRepo {
+	t.Helper()
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testRepo{t: t, r: r, w: w, when: epoch}
+}
+
+// commit writes the files, deleting the ones whose contents are empty, and
+// commits them as author.
+func (tr *testRepo) commit(author string, files map[string]string) *object.Commit {
+	tr.t.Helper()
+	tr.write(files)
+	return tr.commitIndex(author, "change by "+author)
+}
+
+// write writes the files to the index, deleting the ones whose contents are
+// empty.
+func (tr *testRepo) write(files map[string]string) {
+	tr.t.Helper()
+	for name, contents := range files {
+		if contents == "" {
+			if _, err := tr.w.Remove(name); err != nil {
+				tr.t.Fatal(err)
+			}
+			continue
+		}
+		if err := util.WriteFile(tr.w.Filesystem, name, []byte(contents), 0644); err != nil {
+			tr.t.Fatal(err)
+		}
+		if _, err := tr.w.Add(name); err != nil {
+			tr.t.Fatal(err)
+		}
+	}
+}
+
+// commitIndex commits the index as author.
+func (tr *testRepo) commitIndex(author, message string, parents ...plumbing.Hash) *object.Commit {
+	tr.t.Helper()
+	tr.when = tr.when.Add(time.Hour)
+	sig := &object.Signature{Name: author, Email: author, When: tr.when}
+	opts := &git.CommitOptions{Author: sig, Committer: sig}
+	if len(parents) != 0 {
+		head, err := tr.r.Head()
+		if err != nil {
+			tr.t.Fatal(err)
+		}
+		opts.Parents = append([]plumbing.Hash{head.Hash()}, parents...)
+	}
+	h, err := tr.w.Commit(message, opts)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	c, err := tr.r.CommitObject(h)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	return c
+}
+
+// at dates the next commit days after the start of the history.
+func (tr *testRepo) at(days int) {
+	tr.when = epoch.Add(time.Duration(days)*24*time.Hour - time.Hour)
+}
+
+// checkout checks out the branch name, creating it at HEAD if create is set.
+func (tr *testRepo) checkout(name string, create bool) {
+	tr.t.Helper()
+	err := tr.w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name), Create: create})
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+}
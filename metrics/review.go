@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// ReviewOptions describes the change reviewed.
+type ReviewOptions struct {
+	// Author is the email of the author of the change, the author of the
+	// head if empty. The lines of the author are self churn.
+	Author string
+	// Prefix, if not empty, restricts the files to the ones below it.
+	Prefix string
+	// MaxReviewers, if not 0, is the number of reviewers suggested.
+	MaxReviewers int
+}
+
+// LineAge is the age in days of lines when they are deleted.
+type LineAge struct {
+	Newest int
+	Median int
+	Oldest int
+}
+
+// LineOwner is an author of lines deleted by a change.
+type LineOwner struct {
+	Author string
+	Lines  int
+}
+
+// FileReview is the churn of a file by a change.
+type FileReview struct {
+	File string
+	// Deleted is the number of lines of the merge base deleted, or changed,
+	// by the change, but the moved ones.
+	Deleted          int
+	SelfChurn        int
+	InteractiveChurn int
+	Age              LineAge
+	// Owners are the authors of the deleted lines, by decreasing lines.
+	Owners []LineOwner
+}
+
+// Reviewer is an author of lines deleted by a change, suggested to review
+// it.
+type Reviewer struct {
+	Author string
+	// Lines is the number of their lines deleted, in Files files.
+	Lines int
+	Files int
+	// LastChange is when the most recent of these lines was authored.
+	LastChange time.Time
+}
+
+// ReviewReport is the churn of a change: the lines of the merge base of the
+// base and the head that the head deletes.
+type ReviewReport struct {
+	Base      string
+	Head      string
+	MergeBase string
+	Author    string
+	// Deleted, SelfChurn, InteractiveChurn and Age are the ones of all the
+	// files.
+	Deleted          int
+	SelfChurn        int
+	InteractiveChurn int
+	Age              LineAge
+	// Files are the files whose lines are deleted, by decreasing
+	// interactive churn.
+	Files []FileReview
+	// Reviewers are the other authors of the deleted lines, by decreasing
+	// lines.
+	Reviewers []Reviewer
+}
+
+// Review returns the churn of the change from the merge base of the revisions
+// base and head to head: the head is attributed as a single commit on top of
+// the merge base, so that the lines the branch deletes are traced back to
+// their authors in the history of the base.
+func Review(ctx context.Context, r *git.Repository, base, head string, opts ReviewOptions) (*ReviewReport, error) {
+	baseCommit, err := resolveCommit(r, base)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := resolveCommit(r, head)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := baseCommit.MergeBase(headCommit)
+	if err != nil {
+		return nil, wrapError(err, headCommit.Hash.String(), "")
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%s and %s have no merge base", base, head)
+	}
+
+	author := headCommit.Author
+	author.When = headCommit.Committer.When
+	if opts.Author != "" {
+		author.Name, author.Email = opts.Author, opts.Author
+	}
+	change, err := virtualCommit(r.Storer, headCommit.TreeHash, bases[0], author,
+		fmt.Sprintf("Review of %s..%s\n", base, head))
+	if err != nil {
+		return nil, wrapError(err, headCommit.Hash.String(), "")
+	}
+	report := &ReviewReport{
+		Base:      baseCommit.Hash.String(),
+		Head:      headCommit.Hash.String(),
+		MergeBase: bases[0].Hash.String(),
+		Author:    author.Email,
+	}
+	if err := report.attribute(ctx, change, opts); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// virtualCommit returns a commit of the tree on top of parent that is not
+// stored, its objects being looked up in s.
+func virtualCommit(s storer.EncodedObjectStorer, tree plumbing.Hash, parent *object.Commit, author object.Signature,
+	message string) (*object.Commit, error) {
+	c := &object.Commit{
+		Author:       author,
+		Committer:    author,
+		Message:      message,
+		TreeHash:     tree,
+		ParentHashes: []plumbing.Hash{parent.Hash},
+	}
+	obj := &plumbing.MemoryObject{}
+	if err := c.Encode(obj); err != nil {
+		return nil, err
+	}
+	return object.DecodeCommit(s, obj)
+}
+
+// attribute fills the report with the lines of the parent of the virtual
+// commit change that it deletes.
+func (report *ReviewReport) attribute(ctx context.Context, change *object.Commit, opts ReviewOptions) error {
+	parent, err := change.Parent(0)
+	if err != nil {
+		return wrapError(err, change.Hash.String(), "")
+	}
+	byFile := make(map[string]*FileReview)
+	owners := make(map[string]map[string]int)
+	ages := make(map[string][]int)
+	reviewers := make(map[string]*Reviewer)
+	reviewerFiles := make(map[string]map[string]struct{})
+
+	b := new(blame)
+	b.fRev = parent
+	b.onDelete = func(file string, origin, by *object.Commit) {
+		if by != change || !hasPathPrefix(file, opts.Prefix) {
+			return
+		}
+		f, ok := byFile[file]
+		if !ok {
+			f = &FileReview{File: file}
+			byFile[file] = f
+			owners[file] = make(map[string]int)
+		}
+		f.Deleted++
+		email := origin.Author.Email
+		owners[file][email]++
+		age := int(change.Author.When.Sub(origin.Author.When).Hours() / 24)
+		if age < 0 {
+			age = 0
+		}
+		ages[file] = append(ages[file], age)
+		if email == change.Author.Email {
+			f.SelfChurn++
+			return
+		}
+		f.InteractiveChurn++
+		reviewer, ok := reviewers[email]
+		if !ok {
+			reviewer = &Reviewer{Author: email}
+			reviewers[email] = reviewer
+			reviewerFiles[email] = make(map[string]struct{})
+		}
+		reviewer.Lines++
+		reviewerFiles[email][file] = struct{}{}
+		if origin.Author.When.After(reviewer.LastChange) {
+			reviewer.LastChange = origin.Author.When
+		}
+	}
+	if err := b.fillRevs(ctx); err != nil {
+		return err
+	}
+	b.revs = append(b.revs, change)
+	if err := b.fillGraphAndData(ctx); err != nil {
+		return err
+	}
+
+	var allAges []int
+	for name, f := range byFile {
+		f.Age = lineAge(ages[name])
+		allAges = append(allAges, ages[name]...)
+		for email, lines := range owners[name] {
+			f.Owners = append(f.Owners, LineOwner{Author: email, Lines: lines})
+		}
+		sort.Slice(f.Owners, func(i, j int) bool {
+			if f.Owners[i].Lines != f.Owners[j].Lines {
+				return f.Owners[i].Lines > f.Owners[j].Lines
+			}
+			return f.Owners[i].Author < f.Owners[j].Author
+		})
+		report.Deleted += f.Deleted
+		report.SelfChurn += f.SelfChurn
+		report.InteractiveChurn += f.InteractiveChurn
+		report.Files = append(report.Files, *f)
+	}
+	report.Age = lineAge(allAges)
+	sort.Slice(report.Files, func(i, j int) bool {
+		a, b := report.Files[i], report.Files[j]
+		switch {
+		case a.InteractiveChurn != b.InteractiveChurn:
+			return a.InteractiveChurn > b.InteractiveChurn
+		case a.Deleted != b.Deleted:
+			return a.Deleted > b.Deleted
+		default:
+			return a.File < b.File
+		}
+	})
+
+	for email, reviewer := range reviewers {
+		reviewer.Files = len(reviewerFiles[email])
+		report.Reviewers = append(report.Reviewers, *reviewer)
+	}
+	sort.Slice(report.Reviewers, func(i, j int) bool {
+		a, b := report.Reviewers[i], report.Reviewers[j]
+		switch {
+		case a.Lines != b.Lines:
+			return a.Lines > b.Lines
+		case !a.LastChange.Equal(b.LastChange):
+			return a.LastChange.After(b.LastChange)
+		default:
+			return a.Author < b.Author
+		}
+	})
+	if opts.MaxReviewers > 0 && len(report.Reviewers) > opts.MaxReviewers {
+		report.Reviewers = report.Reviewers[:opts.MaxReviewers]
+	}
+	return nil
+}
+
+// lineAge returns the newest, median and oldest of the ages.
+func lineAge(ages []int) LineAge {
+	if len(ages) == 0 {
+		return LineAge{}
+	}
+	sort.Ints(ages)
+	return LineAge{Newest: ages[0], Median: ages[len(ages)/2], Oldest: ages[len(ages)-1]}
+}
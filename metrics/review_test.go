@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// The lines of the merge base that a branch deletes are attributed to their
+// authors, whatever the branch did to them before.
+func TestReview(t *testing.T) {
+	tr := newTestRepo(t)
+	a, b := lines("a", 6), lines("b", 3)
+	tr.at(0)
+	tr.commit("alice", map[string]string{"a.txt": a, "b.txt": b})
+	tr.at(5)
+	tr.commit("erin", map[string]string{"e.txt": "e1\n"})
+	a = strings.NewReplacer("a5\n", "x5\n", "a6\n", "x6\n").Replace(a)
+	tr.at(10)
+	mergeBase := tr.commit("bob", map[string]string{"a.txt": a})
+	tr.checkout("feature", true)
+	tr.at(18)
+	tr.commit("erin", map[string]string{"a.txt": strings.Replace(a, "a1\n", "z1\n", 1)})
+	tr.at(20)
+	tr.commit("erin", map[string]string{
+		"a.txt": strings.NewReplacer("a1\n", "y1\n", "x5\n", "y5\n", "x6\n", "y6\n").Replace(a),
+		"b.txt": "b3\n",
+		"e.txt": "e9\n",
+	})
+	tr.checkout("master", false)
+	tr.at(15)
+	tr.commit("carol", map[string]string{"c.txt": "c1\n"})
+
+	report, err := metrics.Review(context.Background(), tr.r, "master", "feature", metrics.ReviewOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MergeBase != mergeBase.Hash.String() {
+		t.Errorf("merge base %s, want %s", report.MergeBase, mergeBase.Hash)
+	}
+	if report.Author != "erin" {
+		t.Errorf("author %q, want erin", report.Author)
+	}
+	if report.Deleted != 6 || report.SelfChurn != 1 || report.InteractiveChurn != 5 {
+		t.Errorf("deleted %d, self churn %d, interactive churn %d, want 6, 1 and 5",
+			report.Deleted, report.SelfChurn, report.InteractiveChurn)
+	}
+	if want := (metrics.LineAge{Newest: 10, Median: 20, Oldest: 20}); report.Age != want {
+		t.Errorf("age %+v, want %+v", report.Age, want)
+	}
+	files := []metrics.FileReview{
+		{File: "a.txt", Deleted: 3, InteractiveChurn: 3, Age: metrics.LineAge{Newest: 10, Median: 10, Oldest: 20},
+			Owners: []metrics.LineOwner{{Author: "bob", Lines: 2}, {Author: "alice", Lines: 1}}},
+		{File: "b.txt", Deleted: 2, InteractiveChurn: 2, Age: metrics.LineAge{Newest: 20, Median: 20, Oldest: 20},
+			Owners: []metrics.LineOwner{{Author: "alice", Lines: 2}}},
+		{File: "e.txt", Deleted: 1, SelfChurn: 1, Age: metrics.LineAge{Newest: 15, Median: 15, Oldest: 15},
+			Owners: []metrics.LineOwner{{Author: "erin", Lines: 1}}},
+	}
+	if !reflect.DeepEqual(report.Files, files) {
+		t.Errorf("files %+v, want %+v", report.Files, files)
+	}
+	day := func(days int) time.Time {
+		return epoch.Add(time.Duration(days) * 24 * time.Hour)
+	}
+	reviewers := []metrics.Reviewer{
+		{Author: "alice", Lines: 3, Files: 2, LastChange: day(0)},
+		{Author: "bob", Lines: 2, Files: 1, LastChange: day(10)},
+	}
+	if !equalReviewers(report.Reviewers, reviewers) {
+		t.Errorf("reviewers %+v, want %+v", report.Reviewers, reviewers)
+	}
+
+	report, err = metrics.Review(context.Background(), tr.r, "master", "feature",
+		metrics.ReviewOptions{Author: "bob", MaxReviewers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SelfChurn != 2 || report.InteractiveChurn != 4 {
+		t.Errorf("self churn %d and interactive churn %d of bob, want 2 and 4", report.SelfChurn, report.InteractiveChurn)
+	}
+	if !equalReviewers(report.Reviewers, reviewers[:1]) {
+		t.Errorf("reviewers %+v, want %+v", report.Reviewers, reviewers[:1])
+	}
+}
+
+// equalReviewers tells if the reviewers are the same, whatever the locations
+// of their dates.
+func equalReviewers(got, want []metrics.Reviewer) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if g.Author != w.Author || g.Lines != w.Lines || g.Files != w.Files || !g.LastChange.Equal(w.LastChange) {
+			return false
+		}
+	}
+	return true
+}
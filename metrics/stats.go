@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	rtmetrics "runtime/metrics"
+	"sync"
+	"time"
+)
+
+// The phases measured by Stats. Blob reads, diffs and output writing are part
+// of processing revisions.
+const (
+	StatsClone       = "clone"
+	StatsHistoryWalk = "history walk"
+	StatsProcessing  = "processing revisions"
+	StatsBlobReads   = "blob reads"
+	StatsDiffs       = "diffs"
+	StatsOutput      = "output writing"
+)
+
+// Stats collects the time and the allocations spent in every phase of a run,
+// and counts of the work done. The allocations are read from runtime/metrics,
+// which does not stop the world, but they are only approximate for the
+// phases as short as a blob read or a diff.
+type Stats struct {
+	mu     sync.Mutex
+	phases []*PhaseStats
+
+	Commits   int
+	Files     int
+	Blobs     int
+	DiffBytes int64
+}
+
+// PhaseStats is the time and the allocations spent in a phase.
+type PhaseStats struct {
+	Phase string
+	// Calls is the number of times the phase was entered.
+	Calls      int
+	Duration   time.Duration
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+type statsKey struct{}
+
+// WithStats returns a context that collects the stats of the runs it is
+// passed to in s.
+func WithStats(ctx context.Context, s *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, s)
+}
+
+func statsFrom(ctx context.Context) *Stats {
+	s, _ := ctx.Value(statsKey{}).(*Stats)
+	return s
+}
+
+// Phases returns the stats of the phases, in the order they were first
+// entered.
+func (s *Stats) Phases() []PhaseStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]PhaseStats, len(s.phases))
+	for i, p := range s.phases {
+		result[i] = *p
+	}
+	return result
+}
+
+// track starts measuring phase and returns the function that stops it. It
+// does nothing if s is nil.
+func (s *Stats) track(phase string) func() {
+	if s == nil {
+		return func() {}
+	}
+	allocs, bytes := readAllocs()
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		afterAllocs, afterBytes := readAllocs()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		p := s.phase(phase)
+		p.Calls++
+		p.Duration += elapsed
+		p.Allocs += afterAllocs - allocs
+		p.AllocBytes += afterBytes - bytes
+	}
+}
+
+// readAllocs returns the number of heap allocations so far and their bytes.
+func readAllocs() (allocs, bytes uint64) {
+	samples := []rtmetrics.Sample{{Name: "/gc/heap/allocs:objects"}, {Name: "/gc/heap/allocs:bytes"}}
+	rtmetrics.Read(samples)
+	for _, sample := range samples {
+		if sample.Value.Kind() != rtmetrics.KindUint64 {
+			return 0, 0
+		}
+	}
+	return samples[0].Value.Uint64(), samples[1].Value.Uint64()
+}
+
+func (s *Stats) phase(name string) *PhaseStats {
+	for _, p := range s.phases {
+		if p.Phase == name {
+			return p
+		}
+	}
+	p := &PhaseStats{Phase: name}
+	s.phases = append(s.phases, p)
+	return p
+}
+
+// count adds to the counts of the work done. It does nothing if s is nil.
+func (s *Stats) count(commits, files, blobs int, diffBytes int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Commits += commits
+	s.Files += files
+	s.Blobs += blobs
+	s.DiffBytes += diffBytes
+}
@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/ashishgalagali/go-git-churn/synth"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestStats(t *testing.T) {
+	result, err := synth.Build(synth.DefaultSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := result.Repository.CommitObject(plumbing.NewHash(result.Head))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats metrics.Stats
+	if _, err := metrics.Churns(metrics.WithStats(context.Background(), &stats), head, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Commits != synth.DefaultSpec.Commits || stats.Blobs == 0 || stats.Blobs != stats.Files {
+		t.Errorf("%d commits, %d files and %d blobs, want %d commits", stats.Commits, stats.Files, stats.Blobs,
+			synth.DefaultSpec.Commits)
+	}
+	phases := make(map[string]metrics.PhaseStats)
+	for _, p := range stats.Phases() {
+		phases[p.Phase] = p
+	}
+	for name, calls := range map[string]int{
+		metrics.StatsHistoryWalk: 1,
+		metrics.StatsProcessing:  1,
+		metrics.StatsBlobReads:   stats.Blobs,
+	} {
+		if p := phases[name]; p.Calls != calls {
+			t.Errorf("%s: %d calls, want %d", name, p.Calls, calls)
+		}
+	}
+	processing := phases[metrics.StatsProcessing]
+	if processing.Allocs == 0 || processing.AllocBytes < phases[metrics.StatsDiffs].AllocBytes {
+		t.Errorf("processing revisions allocated %d bytes, want more than the %d bytes of the diffs",
+			processing.AllocBytes, phases[metrics.StatsDiffs].AllocBytes)
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// The groups survival curves can be computed for.
+const (
+	GroupRepository = "repository"
+	GroupDirectory  = "directory"
+	GroupFile       = "file"
+	GroupAuthor     = "author"
+	GroupCohort     = "cohort"
+)
+
+// SurvivalGroups are all the groups survival curves can be computed for.
+var SurvivalGroups = []string{GroupRepository, GroupDirectory, GroupFile, GroupAuthor, GroupCohort}
+
+// LineLifetime is the time a line survived in the history of a repository.
+type LineLifetime struct {
+	File   string
+	Author string
+	// Born is the author date of the commit that introduced the line.
+	Born time.Time
+	// Days is the number of whole days between Born and the deletion of the
+	// line, or the final revision if the line was not deleted.
+	Days int
+	// Deleted is false if the line is alive in the final revision, its
+	// lifetime is then censored.
+	Deleted bool
+}
+
+// SurvivalCurve is the Kaplan-Meier estimate of the survival function of the
+// lines of a group.
+type SurvivalCurve struct {
+	Group    string
+	Key      string
+	Lines    int
+	Deleted  int
+	Censored int
+	// MedianDays is the half-life of the lines: the number of days after
+	// which half of them are estimated to be deleted. It is nil if the
+	// survival never drops to one half.
+	MedianDays *int
+	Points     []SurvivalPoint
+}
+
+// SurvivalPoint is a step of a survival curve.
+type SurvivalPoint struct {
+	Days     int
+	AtRisk   int
+	Deleted  int
+	Survival float64
+}
+
+// Lifetimes returns the lifetime of every line introduced in the history of
+// commit c, in the files whose path starts with prefix. The lines alive at c
+// are censored at the date of c.
+//
+// Deleting a file ends the lifetime of all its lines, and moved or renamed
+// lines are deleted and introduced again.
+func Lifetimes(ctx context.Context, c *object.Commit, prefix string) ([]LineLifetime, error) {
+	var result []LineLifetime
+	b := new(blame)
+	b.fRev = c
+	b.onDelete = func(file string, origin, by *object.Commit) {
+		if origin == nil || !hasPathPrefix(file, prefix) {
+			return
+		}
+		result = append(result, newLineLifetime(file, origin, by.Author.When, true))
+	}
+	if err := b.fillRevs(ctx); err != nil {
+		return nil, err
+	}
+	if err := b.fillGraphAndData(ctx); err != nil {
+		return nil, err
+	}
+
+	i, ok := b.commitIndexMap[c.Hash.String()]
+	if !ok {
+		return result, nil
+	}
+	for name, revs := range b.graph {
+		if !hasPathPrefix(name, prefix) {
+			continue
+		}
+		for _, origin := range revs[i] {
+			if origin != nil {
+				result = append(result, newLineLifetime(name, origin, c.Author.When, false))
+			}
+		}
+	}
+	return result, nil
+}
+
+func newLineLifetime(file string, origin *object.Commit, end time.Time, deleted bool) LineLifetime {
+	days := int(end.Sub(origin.Author.When).Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	return LineLifetime{
+		File:    file,
+		Author:  origin.Author.Email,
+		Born:    origin.Author.When,
+		Days:    days,
+		Deleted: deleted,
+	}
+}
+
+// SurvivalCurves returns the survival curve of the lifetimes of every key of
+// the given group, sorted by key.
+func SurvivalCurves(lifetimes []LineLifetime, group string) []SurvivalCurve {
+	byKey := make(map[string][]LineLifetime)
+	for _, l := range lifetimes {
+		for _, key := range groupKeys(l, group) {
+			byKey[key] = append(byKey[key], l)
+		}
+	}
+
+	result := make([]SurvivalCurve, 0, len(byKey))
+	for key, ls := range byKey {
+		curve := kaplanMeier(ls)
+		curve.Group = group
+		curve.Key = key
+		result = append(result, curve)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// groupKeys returns the keys of the given group a lifetime belongs to. A line
+// belongs to all the directories above its file.
+func groupKeys(l LineLifetime, group string) []string {
+	switch group {
+	case GroupRepository:
+		return []string{"."}
+	case GroupDirectory:
+		var keys []string
+		for dir := path.Dir(l.File); dir != "."; dir = path.Dir(dir) {
+			keys = append(keys, dir)
+		}
+		return keys
+	case GroupFile:
+		return []string{l.File}
+	case GroupAuthor:
+		return []string{l.Author}
+	case GroupCohort:
+		return []string{l.Born.UTC().Format("2006-01")}
+	default:
+		return nil
+	}
+}
+
+// kaplanMeier estimates the survival function of the given lifetimes.
+func kaplanMeier(lifetimes []LineLifetime) SurvivalCurve {
+	sorted := make([]LineLifetime, len(lifetimes))
+	copy(sorted, lifetimes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Days < sorted[j].Days
+	})
+
+	curve := SurvivalCurve{Lines: len(sorted)}
+	survival := 1.0
+	atRisk := len(sorted)
+	for i := 0; i < len(sorted); {
+		days := sorted[i].Days
+		deleted, censored := 0, 0
+		for ; i < len(sorted) && sorted[i].Days == days; i++ {
+			if sorted[i].Deleted {
+				deleted++
+			} else {
+				censored++
+			}
+		}
+		if deleted != 0 {
+			survival *= 1 - float64(deleted)/float64(atRisk)
+			curve.Points = append(curve.Points, SurvivalPoint{
+				Days:     days,
+				AtRisk:   atRisk,
+				Deleted:  deleted,
+				Survival: survival,
+			})
+			if curve.MedianDays == nil && survival <= 0.5 {
+				median := days
+				curve.MedianDays = &median
+			}
+		}
+		curve.Deleted += deleted
+		curve.Censored += censored
+		atRisk -= deleted + censored
+	}
+	return curve
+}
@@ -0,0 +1,89 @@
+package metrics_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// The lines deleted on a branch live until their deletion, not until the
+// merge of the branch, and the lines alive at the end are censored.
+func TestSurvival(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.at(0)
+	tr.commit("alice", map[string]string{"a.txt": lines("a", 4)})
+	tr.at(10)
+	tr.commit("bob", map[string]string{"a.txt": "a2\na3\na4\n"})
+	tr.checkout("feature", true)
+	tr.at(20)
+	feature := tr.commit("carol", map[string]string{"a.txt": "a3\na4\n"})
+	tr.checkout("master", false)
+	tr.at(30)
+	tr.commit("dave", map[string]string{"d.txt": "d1\n"})
+	tr.at(40)
+	tr.write(map[string]string{"a.txt": "a3\na4\n"})
+	tr.commitIndex("dave", "merge feature", feature.Hash)
+	tr.at(50)
+	head := tr.commit("erin", map[string]string{"a.txt": "a4\n"})
+
+	lifetimes, err := metrics.Lifetimes(context.Background(), head, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type lifetime struct {
+		Author  string
+		Days    int
+		Deleted bool
+	}
+	var got []lifetime
+	for _, l := range lifetimes {
+		got = append(got, lifetime{l.Author, l.Days, l.Deleted})
+	}
+	sort.Slice(got, func(i, j int) bool {
+		if got[i].Days != got[j].Days {
+			return got[i].Days < got[j].Days
+		}
+		return got[i].Author < got[j].Author
+	})
+	want := []lifetime{{"alice", 10, true}, {"alice", 20, true}, {"dave", 20, false}, {"alice", 50, true},
+		{"alice", 50, false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("lifetimes %v, want %v", got, want)
+	}
+
+	curves := metrics.SurvivalCurves(lifetimes, metrics.GroupRepository)
+	if len(curves) != 1 {
+		t.Fatalf("%d curves, want 1", len(curves))
+	}
+	curve := curves[0]
+	if curve.Lines != 5 || curve.Deleted != 3 || curve.Censored != 2 || curve.MedianDays == nil ||
+		*curve.MedianDays != 50 {
+		t.Errorf("curve %+v, want 5 lines, 3 deleted, 2 censored and a half-life of 50 days", curve)
+	}
+	points := []metrics.SurvivalPoint{
+		{Days: 10, AtRisk: 5, Deleted: 1, Survival: 0.8},
+		{Days: 20, AtRisk: 4, Deleted: 1, Survival: 0.6},
+		{Days: 50, AtRisk: 2, Deleted: 1, Survival: 0.3},
+	}
+	if len(curve.Points) != len(points) {
+		t.Fatalf("points %+v, want %+v", curve.Points, points)
+	}
+	for i, p := range curve.Points {
+		survival := p.Survival
+		p.Survival = points[i].Survival
+		if p != points[i] || math.Abs(survival-points[i].Survival) > 1e-9 {
+			t.Errorf("point %d: %+v with survival %v, want %+v", i, p, survival, points[i])
+		}
+	}
+
+	// the lines of dave never drop below one half
+	for _, curve := range metrics.SurvivalCurves(lifetimes, metrics.GroupAuthor) {
+		if curve.Key == "dave" && (curve.MedianDays != nil || len(curve.Points) != 0 || curve.Censored != 1) {
+			t.Errorf("curve of dave %+v, want 1 line censored", curve)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package metrics
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/linediff"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// SZZOptions describes the fix commits whose bug-introducing commits SZZ
+// finds, and the commits it skips.
+type SZZOptions struct {
+	// Fixes are the hashes, or unique prefixes of at least 4 characters, of
+	// fix commits.
+	Fixes []string
+	// Pattern, if not nil, matches the messages of fix commits.
+	Pattern *regexp.Regexp
+	// Issues are the issues fixed by the commits whose messages mention
+	// their ids.
+	Issues []Issue
+	// MaxFiles is the number of files above which a commit is a mass
+	// change, e.g. a reformatting, whose lines keep the origin of the lines
+	// they replace. It is not checked if it is 0. The commits that only
+	// change whitespace are mass changes too.
+	MaxFiles int
+	// Prefix, if not empty, restricts the deleted lines to the files below
+	// it.
+	Prefix string
+}
+
+// Issue is an issue fixed by the commits whose messages mention its id.
+type Issue struct {
+	ID string
+	// Reported, if not zero, is when the issue was reported: the commits
+	// authored after it can not have introduced the bug.
+	Reported time.Time
+}
+
+// BugFix is a fix commit and the commits that introduced the lines it
+// deleted or changed, the candidate bug-introducing commits.
+type BugFix struct {
+	CommitID string
+	Author   string
+	Date     time.Time
+	Summary  string
+	Issues   []string `json:",omitempty"`
+	// Candidates are sorted by decreasing number of lines.
+	Candidates []BugCandidate
+	// Dropped is the number of candidates dropped because they were authored
+	// after the report of an issue of the fix.
+	Dropped int `json:",omitempty"`
+}
+
+// BugCandidate is a commit that introduced lines deleted or changed by a
+// fix.
+type BugCandidate struct {
+	CommitID string
+	Author   string
+	Date     time.Time
+	// Lines is the number of lines of the commit deleted or changed by the
+	// fix, in Files.
+	Lines int
+	Files []string
+}
+
+// SZZ returns the candidate bug-introducing commits of the fix commits in
+// the history of commit c, in the order of the history. The lines deleted or
+// changed by a fix are traced back to the commits that introduced them,
+// leaving out the blank lines, the comments and the lines whose whitespace
+// only changed, and looking through the mass changes.
+func SZZ(ctx context.Context, c *object.Commit, opts SZZOptions) ([]BugFix, error) {
+	issues := make([]*regexp.Regexp, len(opts.Issues))
+	for i, issue := range opts.Issues {
+		issues[i] = regexp.MustCompile(`(^|[^\w-])` + regexp.QuoteMeta(issue.ID) + `($|\W)`)
+	}
+
+	b := new(blame)
+	b.fRev = c
+	if err := b.fillRevs(ctx); err != nil {
+		return nil, err
+	}
+	var result []BugFix
+	fixes := make(map[plumbing.Hash]int)
+	for _, rev := range b.revs {
+		if fix, ok := opts.fix(rev, issues); ok && rev.NumParents() <= 1 {
+			fixes[rev.Hash] = len(result)
+			result = append(result, fix)
+		}
+	}
+
+	// the fixes and the candidates dropped from them
+	dropped := make(map[[2]plumbing.Hash]struct{})
+	b.skip = func(rev *object.Commit, diffs []fileDiff) bool {
+		// the lines of a fix are traced back even if it is a mass change,
+		// only the mass changes among its candidates are looked through
+		if _, ok := fixes[rev.Hash]; ok {
+			return false
+		}
+		return opts.massChange(diffs)
+	}
+	b.onDiff = func(file string, hunks []linediff.Hunk, origins []*object.Commit, by *object.Commit) {
+		i, ok := fixes[by.Hash]
+		if !ok || !hasPathPrefix(file, opts.Prefix) {
+			return
+		}
+		reported := opts.reported(result[i].Issues)
+		for _, origin := range changedOrigins(file, hunks, origins) {
+			if !reported.IsZero() && origin.Author.When.After(reported) {
+				if _, ok := dropped[[2]plumbing.Hash{by.Hash, origin.Hash}]; !ok {
+					dropped[[2]plumbing.Hash{by.Hash, origin.Hash}] = struct{}{}
+					result[i].Dropped++
+				}
+				continue
+			}
+			result[i].addCandidate(origin, file)
+		}
+	}
+	if err := b.fillGraphAndData(ctx); err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		candidates := result[i].Candidates
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].Lines != candidates[j].Lines {
+				return candidates[i].Lines > candidates[j].Lines
+			}
+			return candidates[i].Date.Before(candidates[j].Date)
+		})
+	}
+	return result, nil
+}
+
+// fix returns the fix of the commit c and tells if c is a fix, given the
+// patterns of the ids of the issues.
+func (o SZZOptions) fix(c *object.Commit, issues []*regexp.Regexp) (BugFix, bool) {
+	fix := BugFix{
+		CommitID: c.Hash.String(),
+		Author:   c.Author.Email,
+		Date:     c.Author.When,
+		Summary:  strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0],
+	}
+	for i, issue := range issues {
+		if issue.MatchString(c.Message) {
+			fix.Issues = append(fix.Issues, o.Issues[i].ID)
+		}
+	}
+	isFix := len(fix.Issues) != 0 || o.listed(c) || o.Pattern != nil && o.Pattern.MatchString(c.Message)
+	return fix, isFix
+}
+
+// listed tells if the commit c is one of the listed fixes.
+func (o SZZOptions) listed(c *object.Commit) bool {
+	hash := c.Hash.String()
+	for _, fix := range o.Fixes {
+		if len(fix) >= 4 && strings.HasPrefix(hash, strings.ToLower(fix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reported returns the earliest report date of the given issues, zero if
+// there is none.
+func (o SZZOptions) reported(ids []string) time.Time {
+	var reported time.Time
+	for _, id := range ids {
+		for _, issue := range o.Issues {
+			if issue.ID == id && !issue.Reported.IsZero() && (reported.IsZero() || issue.Reported.Before(reported)) {
+				reported = issue.Reported
+			}
+		}
+	}
+	return reported
+}
+
+// massChange tells if the diffs of a commit make it a mass change: it
+// changes more than MaxFiles files, or only whitespace.
+func (o SZZOptions) massChange(diffs []fileDiff) bool {
+	files, changed := 0, false
+	for _, d := range diffs {
+		deleted, inserted := changedLines(d.hunks)
+		if len(deleted) == 0 && len(inserted) == 0 {
+			continue
+		}
+		files++
+		if len(deleted) != len(inserted) {
+			changed = true
+			continue
+		}
+		for i := range deleted {
+			if ignoreWhitespace.key(deleted[i].text) != ignoreWhitespace.key(inserted[i].text) {
+				changed = true
+				break
+			}
+		}
+	}
+	return files != 0 && (!changed || o.MaxFiles > 0 && files > o.MaxFiles)
+}
+
+// addCandidate adds the line of file introduced by the commit origin to the
+// candidates of the fix.
+func (f *BugFix) addCandidate(origin *object.Commit, file string) {
+	hash := origin.Hash.String()
+	for i := range f.Candidates {
+		if f.Candidates[i].CommitID == hash {
+			f.Candidates[i].Lines++
+			if f.Candidates[i].Files[len(f.Candidates[i].Files)-1] != file {
+				f.Candidates[i].Files = append(f.Candidates[i].Files, file)
+			}
+			return
+		}
+	}
+	f.Candidates = append(f.Candidates, BugCandidate{
+		CommitID: hash,
+		Author:   origin.Author.Email,
+		Date:     origin.Author.When,
+		Lines:    1,
+		Files:    []string{file},
+	})
+}
+
+// changedOrigins returns the origins of the lines of file deleted by the
+// hunks, but the blank ones, the comments and the ones whose whitespace only
+// changed.
+func changedOrigins(file string, hunks []linediff.Hunk, origins []*object.Commit) []*object.Commit {
+	deleted, inserted := changedLines(hunks)
+	reinserted := make(map[string]int)
+	for _, l := range inserted {
+		reinserted[ignoreWhitespace.key(l.text)]++
+	}
+	var result []*object.Commit
+	for _, l := range deleted {
+		key := ignoreWhitespace.key(l.text)
+		if reinserted[key] > 0 {
+			reinserted[key]--
+			continue
+		}
+		if key == "" || isComment(file, l.text) || l.n >= len(origins) || origins[l.n] == nil {
+			continue
+		}
+		result = append(result, origins[l.n])
+	}
+	return result
+}
+
+// changedLines returns the lines deleted and inserted by the hunks.
+func changedLines(hunks []linediff.Hunk) (deleted, inserted []diffLine) {
+	sl, dl := 0, 0
+	for _, hunk := range hunks {
+		for _, line := range hunk.Lines {
+			switch hunk.Op {
+			case linediff.Equal:
+				sl++
+				dl++
+			case linediff.Insert:
+				inserted = append(inserted, diffLine{lineRef{n: dl}, line})
+				dl++
+			case linediff.Delete:
+				deleted = append(deleted, diffLine{lineRef{n: sl}, line})
+				sl++
+			}
+		}
+	}
+	return deleted, inserted
+}
+
+// commentPrefixes are the prefixes of the comment lines of the languages,
+// by file extension. A star followed by a space, or alone, continues a block
+// comment, unlike the one of a dereference like *p = x.
+var commentPrefixes = map[string][]string{}
+
+func init() {
+	for _, lang := range []struct {
+		extensions string
+		prefixes   []string
+	}{
+		{".c .h .cc .cpp .hpp .cs .go .java .js .jsx .ts .tsx .kt .scala .swift .rs .php .dart",
+			[]string{"//", "/*", "*/", "* ", "*\t"}},
+		{".py .rb .sh .bash .pl .r .yaml .yml .toml .cmake .mk", []string{"#"}},
+		{".sql .lua .hs .ada", []string{"--"}},
+		{".html .xml .md .vue", []string{"<!--"}},
+		{".lisp .clj .el .ini", []string{";"}},
+	} {
+		for _, ext := range strings.Fields(lang.extensions) {
+			commentPrefixes[ext] = lang.prefixes
+		}
+	}
+}
+
+// isComment tells if the line of file is a comment, from its extension.
+func isComment(file, line string) bool {
+	// the space ends the lines that are only a star
+	line = strings.TrimSpace(line) + " "
+	for _, prefix := range commentPrefixes[strings.ToLower(path.Ext(file))] {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
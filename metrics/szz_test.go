@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// candidates returns the authors of the candidates of the only fix in the
+// history of head, and their numbers of lines.
+func candidates(t *testing.T, head *object.Commit, opts metrics.SZZOptions) map[string]int {
+	t.Helper()
+	opts.Pattern = regexp.MustCompile(`^fix`)
+	fixes, err := metrics.SZZ(context.Background(), head, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 {
+		t.Fatalf("%d fixes %+v, want 1", len(fixes), fixes)
+	}
+	result := make(map[string]int)
+	for _, c := range fixes[0].Candidates {
+		result[c.Author] = c.Lines
+	}
+	return result
+}
+
+// The comment lines changed by a fix are left out, but not the dereferences
+// that start with a star like the lines of block comments.
+func TestSZZComments(t *testing.T) {
+	tr := newTestRepo(t)
+	src := "void f(int *p)\n{\n    /* sets p\n     * to 1\n     */\n    *p = 1;\n}\n"
+	tr.commit("alice", map[string]string{"f.c": src})
+	tr.write(map[string]string{"f.c": strings.NewReplacer("p\n", "q\n", "to 1", "to 2", "*p = 1", "*p = 2").Replace(src)})
+	head := tr.commitIndex("bob", "fix f")
+
+	if got, want := candidates(t, head, metrics.SZZOptions{}), map[string]int{"alice": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates %v, want %v", got, want)
+	}
+}
+
+// A fix that is a mass change is traced back, while the mass changes before
+// it are looked through.
+func TestSZZMassChanges(t *testing.T) {
+	tr := newTestRepo(t)
+	files := map[string]string{"a.go": lines("a", 3), "b.go": lines("b", 3), "c.go": lines("c", 3)}
+	tr.commit("alice", files)
+	// only changes whitespace
+	tr.commit("carol", map[string]string{"a.go": strings.Replace(files["a.go"], "a1\n", "  a1\n", 1)})
+	tr.write(map[string]string{
+		"a.go": strings.Replace(files["a.go"], "a1\n", "x1\n", 1),
+		"b.go": strings.Replace(files["b.go"], "b1\n", "x1\n", 1),
+		"c.go": strings.Replace(files["c.go"], "c1\n", "x1\n", 1),
+	})
+	head := tr.commitIndex("bob", "fix everything")
+
+	got := candidates(t, head, metrics.SZZOptions{MaxFiles: 2})
+	if want := map[string]int{"alice": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates %v, want %v", got, want)
+	}
+}
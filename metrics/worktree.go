@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// PendingCommit returns a commit, that is not stored, of the changes of the
+// local repository at repoPath that are not committed: the changes of its
+// working tree, or only the staged ones if staged, on top of its HEAD. Like
+// git commit -a, the changes of the working tree leave out the untracked
+// files, but the ones added with git add -N. The repository is opened in
+// place, it is not cloned, and nothing is written to it. The author of the
+// commit is the user of the repository.
+func PendingCommit(ctx context.Context, repoPath string, staged bool) (*object.Commit, error) {
+	r, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, repoError(repoPath, err)
+	}
+	head, err := resolveCommit(r, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	author, err := pendingAuthor(r)
+	if err != nil {
+		return nil, err
+	}
+	// the commit is the newest one, whatever the dates of the history
+	if !author.When.After(head.Committer.When) {
+		author.When = head.Committer.When.Add(time.Second)
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, wrapError(err, head.Hash.String(), "")
+	}
+	root := ""
+	if !staged {
+		wt, err := r.Worktree()
+		if err != nil {
+			return nil, err
+		}
+		root = wt.Filesystem.Root()
+	}
+	s := &overlayStorer{EncodedObjectStorer: r.Storer, memory: memory.NewStorage()}
+	tree := newTreeNode()
+	for _, e := range idx.Entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if e.Stage != 0 {
+			return nil, fmt.Errorf("%s is not merged", e.Name)
+		}
+		if staged && e.IntentToAdd {
+			continue
+		}
+		entry := object.TreeEntry{Name: e.Name, Mode: e.Mode, Hash: e.Hash}
+		if !staged && !e.SkipWorktree && e.Mode != filemode.Submodule {
+			h, ok, err := worktreeBlob(s, root, e)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			entry.Hash = h
+		}
+		tree.add(strings.Split(e.Name, "/"), entry)
+	}
+	treeHash, err := tree.store(s)
+	if err != nil {
+		return nil, err
+	}
+	message := "Changes not committed\n"
+	if staged {
+		message = "Changes staged\n"
+	}
+	return virtualCommit(s, treeHash, head, author, message)
+}
+
+// pendingAuthor returns the signature of the user of the repository r. Like
+// git, its name and email are each taken from the environment, or else from
+// the author, or else the user, of its configuration.
+func pendingAuthor(r *git.Repository) (object.Signature, error) {
+	author := object.Signature{
+		Name:  os.Getenv("GIT_AUTHOR_NAME"),
+		Email: os.Getenv("GIT_AUTHOR_EMAIL"),
+		When:  time.Now(),
+	}
+	if author.Name != "" && author.Email != "" {
+		return author, nil
+	}
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return author, err
+	}
+	for _, name := range []string{cfg.Author.Name, cfg.User.Name} {
+		if author.Name == "" {
+			author.Name = name
+		}
+	}
+	for _, email := range []string{cfg.Author.Email, cfg.User.Email} {
+		if author.Email == "" {
+			author.Email = email
+		}
+	}
+	if author.Email == "" {
+		return author, errors.New("no author of the changes: user.email is not set")
+	}
+	return author, nil
+}
+
+// worktreeBlob returns the hash of the blob of the file of the index entry e
+// in the working tree at root, storing the blob in s if it changed. ok is
+// false if the file is deleted.
+func worktreeBlob(s storer.EncodedObjectStorer, root string, e *index.Entry) (h plumbing.Hash, ok bool, err error) {
+	name := filepath.Join(root, filepath.FromSlash(e.Name))
+	info, err := os.Lstat(name)
+	if os.IsNotExist(err) {
+		return plumbing.ZeroHash, false, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	var data []byte
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(name)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+		data = []byte(target)
+	} else if data, err = ioutil.ReadFile(name); err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	h = plumbing.ComputeHash(plumbing.BlobObject, data)
+	if h == e.Hash {
+		return h, true, nil
+	}
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(data)))
+	w, err := obj.Writer()
+	if err != nil {
+		return h, false, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return h, false, err
+	}
+	if err := w.Close(); err != nil {
+		return h, false, err
+	}
+	h, err = s.SetEncodedObject(obj)
+	return h, err == nil, err
+}
+
+// treeNode is a directory of a tree being built.
+type treeNode struct {
+	files map[string]object.TreeEntry
+	dirs  map[string]*treeNode
+}
+
+func newTreeNode() *treeNode {
+	return &treeNode{files: make(map[string]object.TreeEntry), dirs: make(map[string]*treeNode)}
+}
+
+// add adds the entry at the path given by its parts.
+func (n *treeNode) add(parts []string, entry object.TreeEntry) {
+	if len(parts) == 1 {
+		entry.Name = parts[0]
+		n.files[parts[0]] = entry
+		return
+	}
+	dir, ok := n.dirs[parts[0]]
+	if !ok {
+		dir = newTreeNode()
+		n.dirs[parts[0]] = dir
+	}
+	dir.add(parts[1:], entry)
+}
+
+// store stores the tree of the directory and its subdirectories in s and
+// returns its hash.
+func (n *treeNode) store(s storer.EncodedObjectStorer) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	for _, entry := range n.files {
+		tree.Entries = append(tree.Entries, entry)
+	}
+	for name, dir := range n.dirs {
+		h, err := dir.store(s)
+		if err != nil {
+			return h, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: h})
+	}
+	// git sorts the directories as if their names ended with a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortName(tree.Entries[i]) < sortName(tree.Entries[j])
+	})
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// overlayStorer stores the objects in memory, and looks them up in memory and
+// then in the storer it embeds, which is never written to.
+type overlayStorer struct {
+	storer.EncodedObjectStorer
+	memory *memory.Storage
+}
+
+func (s *overlayStorer) NewEncodedObject() plumbing.EncodedObject {
+	return s.memory.NewEncodedObject()
+}
+
+func (s *overlayStorer) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	return s.memory.SetEncodedObject(obj)
+}
+
+func (s *overlayStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	if obj, err := s.memory.EncodedObject(t, h); err == nil {
+		return obj, nil
+	}
+	return s.EncodedObjectStorer.EncodedObject(t, h)
+}
+
+func (s *overlayStorer) HasEncodedObject(h plumbing.Hash) error {
+	if s.memory.HasEncodedObject(h) == nil {
+		return nil
+	}
+	return s.EncodedObjectStorer.HasEncodedObject(h)
+}
+
+func (s *overlayStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	if size, err := s.memory.EncodedObjectSize(h); err == nil {
+		return size, nil
+	}
+	return s.EncodedObjectStorer.EncodedObjectSize(h)
+}
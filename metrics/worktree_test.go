@@ -0,0 +1,221 @@
+package metrics_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// treeFiles returns the contents of the files of the tree of c, by name.
+func treeFiles(t *testing.T, c *object.Commit) map[string]string {
+	t.Helper()
+	files, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]string)
+	err = files.ForEach(func(f *object.File) error {
+		contents, err := f.Contents()
+		result[f.Name] = contents
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// withEnv sets the environment variable name for the test, unsetting it if
+// value is empty.
+func withEnv(t *testing.T, name, value string) {
+	saved, ok := os.LookupEnv(name)
+	if value == "" {
+		os.Unsetenv(name)
+	} else {
+		os.Setenv(name, value)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, saved)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// The pending commit of the working tree has its tracked files, and the one
+// of the index only its staged changes.
+func TestPendingCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worktree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	withEnv(t, "GIT_AUTHOR_EMAIL", "bob")
+
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, contents string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add := func(name string) {
+		t.Helper()
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", lines("a", 3))
+	write("d.txt", lines("d", 3))
+	add("a.txt")
+	add("d.txt")
+	sig := &object.Signature{Name: "alice", Email: "alice", When: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	head, err := w.Commit("change by alice", &git.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := strings.Replace(lines("a", 3), "a1\n", "x1\n", 1)
+	write("a.txt", a)
+	write("b.txt", lines("b", 2))
+	add("b.txt")
+	write("b.txt", lines("b", 3))
+	write("c.txt", lines("c", 2))
+	if err := os.Remove(filepath.Join(dir, "d.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		staged bool
+		want   map[string]string
+	}{
+		{false, map[string]string{"a.txt": a, "b.txt": lines("b", 3)}},
+		{true, map[string]string{"a.txt": lines("a", 3), "b.txt": lines("b", 2), "d.txt": lines("d", 3)}},
+	}
+	for _, test := range tests {
+		pending, err := metrics.PendingCommit(context.Background(), dir, test.staged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := treeFiles(t, pending); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("staged %v: files %q, want %q", test.staged, got, test.want)
+		}
+		if pending.Author.Email != "bob" || len(pending.ParentHashes) != 1 || pending.ParentHashes[0] != head {
+			t.Errorf("staged %v: commit by %s on %v, want by bob on %s", test.staged, pending.Author.Email,
+				pending.ParentHashes, head)
+		}
+	}
+
+	// the churn of the working tree deletes a line of alice
+	pending, err := metrics.PendingCommit(context.Background(), dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	churns, err := metrics.Churns(context.Background(), pending, &metrics.ChurnOptions{Paths: []string{"a.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := churns[len(churns)-1]
+	if last.CommitID != pending.Hash.String() || len(last.ChurnFiles) != 1 ||
+		!reflect.DeepEqual(last.ChurnFiles[0].InteractiveChurn, map[string][]int{"alice": {1}}) {
+		t.Errorf("churn of the working tree %+v, want a line of alice deleted", last)
+	}
+
+	// the untracked files added with git add -N are in the working tree
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cmd := exec.Command("git", "add", "-N", "c.txt")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add -N: %v: %s", err, out)
+	}
+	if pending, err = metrics.PendingCommit(context.Background(), dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := treeFiles(t, pending)["c.txt"]; got != lines("c", 2) {
+		t.Errorf("c.txt added with git add -N: %q, want %q", got, lines("c", 2))
+	}
+	if pending, err = metrics.PendingCommit(context.Background(), dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := treeFiles(t, pending)["c.txt"]; ok {
+		t.Error("c.txt added with git add -N is staged")
+	}
+}
+
+// The name and the email of the author of the pending commit are each taken
+// from the environment, or else from the configuration.
+func TestPendingAuthor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "worktree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte(lines("a", 3)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "alice", Email: "alice", When: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := w.Commit("change by alice", &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.User.Name, cfg.User.Email = "Carol", "carol@example.com"
+	if err := r.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, email         string
+		wantName, wantEmail string
+	}{
+		{"", "", "Carol", "carol@example.com"},
+		{"Bob", "", "Bob", "carol@example.com"},
+		{"", "bob@example.com", "Carol", "bob@example.com"},
+		{"Bob", "bob@example.com", "Bob", "bob@example.com"},
+	}
+	for _, test := range tests {
+		withEnv(t, "GIT_AUTHOR_NAME", test.name)
+		withEnv(t, "GIT_AUTHOR_EMAIL", test.email)
+		pending, err := metrics.PendingCommit(context.Background(), dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pending.Author.Name != test.wantName || pending.Author.Email != test.wantEmail {
+			t.Errorf("environment %q <%s>: author %q <%s>, want %q <%s>", test.name, test.email,
+				pending.Author.Name, pending.Author.Email, test.wantName, test.wantEmail)
+		}
+	}
+}
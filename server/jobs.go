@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+// The states of a job.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// JobRequest describes the churn to compute for a job.
+type JobRequest struct {
+	// Repo is the URL or the path of the repository.
+	Repo string
+	// Range is the revision range "from..to", it defaults to HEAD.
+	Range string
+	// Paths, if not empty, restricts the churn to the files below them.
+	Paths []string
+	// Path, if not empty, is the only file whose churn is computed.
+	Path string `json:",omitempty"`
+	// Since and Until, if set, exclude the commits authored before and after
+	// them.
+	Since *time.Time `json:",omitempty"`
+	Until *time.Time `json:",omitempty"`
+	// Moves, if set, detects the moved lines, e.g. ignoring whitespace,
+	// instead of the options of the server.
+	Moves *metrics.MoveOptions `json:",omitempty"`
+}
+
+// JobStatus is the state of a job as reported by the API.
+type JobStatus struct {
+	ID      string
+	Request JobRequest
+	Status  string
+	// Progress is the last progress event of the job, if it is running.
+	Progress *metrics.ProgressEvent `json:",omitempty"`
+	// Results is the number of churn records available.
+	Results  int
+	Error    string `json:",omitempty"`
+	Created  time.Time
+	Started  *time.Time `json:",omitempty"`
+	Finished *time.Time `json:",omitempty"`
+}
+
+// job is a churn computation submitted to the server.
+type job struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	status  JobStatus
+	results []metrics.Churn
+}
+
+func newJob(id string, req JobRequest) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &job{
+		ctx:    ctx,
+		cancel: cancel,
+		status: JobStatus{
+			ID:      id,
+			Request: req,
+			Status:  StatusQueued,
+			Created: time.Now(),
+		},
+	}
+}
+
+// ended returns when the job ended, or nil if it is queued or running.
+func (j *job) ended() *time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Status == StatusQueued || j.status.Status == StatusRunning {
+		return nil
+	}
+	return j.status.Finished
+}
+
+// snapshot returns a copy of the status of the job.
+func (j *job) snapshot() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// page returns at most limit results starting at offset.
+func (j *job) page(offset, limit int) ([]metrics.Churn, int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	total := len(j.results)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return j.results[offset:end], total
+}
+
+// start moves a queued job to running. It returns false if the job was
+// cancelled meanwhile.
+func (j *job) start() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Status != StatusQueued {
+		return false
+	}
+	now := time.Now()
+	j.status.Status = StatusRunning
+	j.status.Started = &now
+	return true
+}
+
+func (j *job) progress(ev metrics.ProgressEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Progress = &ev
+}
+
+// finish records the outcome of a running job, unless it was cancelled.
+func (j *job) finish(results []metrics.Churn, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Status != StatusRunning {
+		return
+	}
+	now := time.Now()
+	j.status.Finished = &now
+	if err != nil {
+		j.status.Status = StatusFailed
+		j.status.Error = err.Error()
+		return
+	}
+	j.status.Status = StatusDone
+	j.results = results
+	j.status.Results = len(results)
+}
+
+// stop cancels the job if it is queued or running. It returns false if the
+// job had already ended.
+func (j *job) stop() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Status != StatusQueued && j.status.Status != StatusRunning {
+		return false
+	}
+	now := time.Now()
+	j.status.Status = StatusCancelled
+	j.status.Finished = &now
+	j.cancel()
+	return true
+}
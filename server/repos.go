@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5"
+)
+
+// repoCache keeps the repositories opened by the jobs, so that the jobs on
+// the same repository do not clone it again but only fetch its new commits.
+// Beyond max repositories, the least recently used ones that no job holds are
+// closed and forgotten.
+type repoCache struct {
+	max int
+
+	mu    sync.Mutex
+	repos map[string]*cachedRepo
+	// used counts the uses of the repositories, to order them
+	used uint64
+}
+
+type cachedRepo struct {
+	// only one job at a time reads a repository
+	sync.Mutex
+	once sync.Once
+	repo *git.Repository
+	err  error
+
+	// the number of jobs holding or waiting for the repository, and the
+	// use it was last released by, guarded by the mutex of the cache
+	holders  int
+	lastUsed uint64
+}
+
+func newRepoCache(max int) *repoCache {
+	return &repoCache{max: max, repos: make(map[string]*cachedRepo)}
+}
+
+// get returns the repository at url, locked for the caller, opening it if it
+// is not in the cache and fetching its new commits otherwise. The caller must
+// release it when done.
+func (c *repoCache) get(ctx context.Context, url string) (*cachedRepo, error) {
+	c.mu.Lock()
+	cr, ok := c.repos[url]
+	if !ok {
+		cr = new(cachedRepo)
+		c.repos[url] = cr
+	}
+	cr.holders++
+	c.mu.Unlock()
+
+	cr.Lock()
+	opened := false
+	cr.once.Do(func() {
+		opened = true
+		cr.repo, cr.err = metrics.OpenRepo(ctx, url)
+	})
+	err := cr.err
+	if err == nil && !opened {
+		// the repository may have changed since it was opened
+		err = metrics.FetchRepo(ctx, cr.repo, url)
+	}
+	if err != nil {
+		c.mu.Lock()
+		// a newer repository may be cached under the same url
+		if cr.err != nil && c.repos[url] == cr {
+			delete(c.repos, url)
+		}
+		c.mu.Unlock()
+		c.release(cr)
+		return nil, err
+	}
+	return cr, nil
+}
+
+// release unlocks the repository got from the cache, and closes the least
+// recently used ones beyond the maximum.
+func (c *repoCache) release(cr *cachedRepo) {
+	cr.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cr.holders--
+	c.used++
+	cr.lastUsed = c.used
+	if len(c.repos) <= c.max {
+		return
+	}
+
+	type idleRepo struct {
+		url string
+		cr  *cachedRepo
+	}
+	var idle []idleRepo
+	for url, cr := range c.repos {
+		if cr.holders == 0 {
+			idle = append(idle, idleRepo{url, cr})
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return idle[i].cr.lastUsed < idle[j].cr.lastUsed
+	})
+	for _, r := range idle {
+		if len(c.repos) <= c.max {
+			break
+		}
+		delete(c.repos, r.url)
+		if r.cr.repo == nil {
+			continue
+		}
+		if closer, ok := r.cr.repo.Storer.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
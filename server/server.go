@@ -0,0 +1,355 @@
+// Package server exposes the churn metrics over a JSON HTTP API.
+//
+// The API has the following endpoints:
+//
+//	POST   /jobs                 submits a JobRequest and returns its JobStatus
+//	GET    /jobs                 lists the status of all the jobs
+//	GET    /jobs/{id}            returns the status and progress of a job
+//	GET    /jobs/{id}/results    returns a page of the churn of a finished job,
+//	                             selected with the offset and limit parameters
+//	DELETE /jobs/{id}            cancels a job
+//
+// Jobs go through a bounded queue and are run by a fixed number of workers.
+// The jobs that ended are forgotten, with their results, after a while or
+// when too many of them are kept. The repositories are kept open for the next
+// jobs, the least recently used first closed when too many of them are.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+)
+
+const defaultPageLimit = 100
+
+var (
+	// ErrQueueFull is returned when a job is submitted while the queue is
+	// full.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrClosed is returned when a job is submitted to a closed Server.
+	ErrClosed = errors.New("server is closed")
+)
+
+// Config is the configuration of a Server.
+type Config struct {
+	// Workers is the number of jobs run concurrently, 1 if not set.
+	Workers int
+	// QueueSize is the number of jobs that can wait for a worker, 16 if not
+	// set.
+	QueueSize int
+	// TTL is how long the jobs that ended are kept, 1 hour if not set.
+	TTL time.Duration
+	// MaxJobs is the number of jobs that ended kept, the oldest first
+	// forgotten, 100 if not set.
+	MaxJobs int
+	// MaxRepos is the number of repositories kept open for the next jobs,
+	// the least recently used first closed, 16 if not set.
+	MaxRepos int
+}
+
+// Server runs the churn jobs submitted through its HTTP API.
+type Server struct {
+	repos   *repoCache
+	queue   chan *job
+	wg      sync.WaitGroup
+	ttl     time.Duration
+	maxJobs int
+	// now returns the current time, to expire the jobs
+	now func() time.Time
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	nextID int
+	closed bool
+}
+
+// New returns a Server whose workers are already waiting for jobs. Close must
+// be called to stop them.
+func New(config Config) *Server {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 16
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Hour
+	}
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = 100
+	}
+	if config.MaxRepos <= 0 {
+		config.MaxRepos = 16
+	}
+	s := &Server{
+		repos:   newRepoCache(config.MaxRepos),
+		queue:   make(chan *job, config.QueueSize),
+		ttl:     config.TTL,
+		maxJobs: config.MaxJobs,
+		now:     time.Now,
+		jobs:    make(map[string]*job),
+	}
+	for i := 0; i < config.Workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// Close cancels all the jobs and waits for the workers to stop.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.stop()
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Submit queues a new job.
+func (s *Server) Submit(req JobRequest) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return JobStatus{}, ErrClosed
+	}
+	s.expire()
+	s.nextID++
+	j := newJob(strconv.Itoa(s.nextID), req)
+	select {
+	case s.queue <- j:
+	default:
+		return JobStatus{}, ErrQueueFull
+	}
+	s.jobs[j.status.ID] = j
+	return j.snapshot(), nil
+}
+
+func (s *Server) job(id string) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	return s.jobs[id]
+}
+
+// expire forgets the jobs that ended longer than the TTL ago, and the oldest
+// ones beyond MaxJobs. s.mu must be held.
+func (s *Server) expire() {
+	type endedJob struct {
+		id    string
+		ended time.Time
+	}
+	var ended []endedJob
+	now := s.now()
+	for id, j := range s.jobs {
+		t := j.ended()
+		if t == nil {
+			continue
+		}
+		if now.Sub(*t) > s.ttl {
+			delete(s.jobs, id)
+			continue
+		}
+		ended = append(ended, endedJob{id, *t})
+	}
+	if len(ended) <= s.maxJobs {
+		return
+	}
+	sort.Slice(ended, func(i, j int) bool {
+		return ended[i].ended.Before(ended[j].ended)
+	})
+	for _, e := range ended[:len(ended)-s.maxJobs] {
+		delete(s.jobs, e.id)
+	}
+}
+
+func (s *Server) work() {
+	defer s.wg.Done()
+	for j := range s.queue {
+		if !j.start() {
+			continue
+		}
+		results, err := s.run(j)
+		j.finish(results, err)
+	}
+}
+
+func (s *Server) run(j *job) ([]metrics.Churn, error) {
+	req := j.snapshot().Request
+	repo, err := s.repos.get(j.ctx, req.Repo)
+	if err != nil {
+		return nil, err
+	}
+	defer s.repos.release(repo)
+
+	from, to, err := metrics.ResolveRange(repo.repo, req.Range)
+	if err != nil {
+		return nil, err
+	}
+	opts := &metrics.ChurnOptions{
+		Path:  req.Path,
+		Paths: req.Paths,
+		From:  from,
+		Moves: req.Moves,
+	}
+	if req.Since != nil {
+		opts.Since = *req.Since
+	}
+	if req.Until != nil {
+		opts.Until = *req.Until
+	}
+	ctx := metrics.WithProgress(j.ctx, j.progress)
+	return metrics.Churns(ctx, to, opts)
+}
+
+// ServeHTTP implements the HTTP API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.handleSubmit(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.handleList(w)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.handleStatus(w, parts[1])
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		s.handleCancel(w, parts[1])
+	case len(parts) == 3 && parts[2] == "results" && r.Method == http.MethodGet:
+		s.handleResults(w, r, parts[1])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job request: "+err.Error())
+		return
+	}
+	if req.Repo == "" {
+		writeError(w, http.StatusBadRequest, "invalid job request: missing Repo")
+		return
+	}
+	if req.Since != nil && req.Until != nil && req.Until.Before(*req.Since) {
+		writeError(w, http.StatusBadRequest, "invalid job request: Until is before Since")
+		return
+	}
+	status, err := s.Submit(req)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *Server) handleList(w http.ResponseWriter) {
+	s.mu.Lock()
+	s.expire()
+	result := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		result = append(result, j.snapshot())
+	}
+	s.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, id string) {
+	j := s.job(id)
+	if j == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, j.snapshot())
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, id string) {
+	j := s.job(id)
+	if j == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if !j.stop() {
+		writeError(w, http.StatusConflict, "job already "+j.snapshot().Status)
+		return
+	}
+	writeJSON(w, http.StatusOK, j.snapshot())
+}
+
+// ResultsPage is a page of the churn computed by a job.
+type ResultsPage struct {
+	Offset int
+	Limit  int
+	Total  int
+	Churns []metrics.Churn
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request, id string) {
+	j := s.job(id)
+	if j == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if status := j.snapshot().Status; status != StatusDone {
+		writeError(w, http.StatusConflict, "job is "+status)
+		return
+	}
+
+	offset, err := intParam(r, "offset", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := intParam(r, "limit", defaultPageLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	churns, total := j.page(offset, limit)
+	writeJSON(w, http.StatusOK, ResultsPage{
+		Offset: offset,
+		Limit:  limit,
+		Total:  total,
+		Churns: churns,
+	})
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + name + " parameter")
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, struct{ Error string }{msg})
+}
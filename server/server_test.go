@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/synth"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepo builds a small repository on disk and returns its directory.
+func testRepo(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	spec := synth.DefaultSpec
+	spec.Commits = 20
+	if _, err := synth.BuildDir(dir, spec); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// clock is a time that the tests move forward.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type testServer struct {
+	t     *testing.T
+	s     *Server
+	http  *httptest.Server
+	clock *clock
+}
+
+func newTestServer(t *testing.T, config Config) *testServer {
+	s := New(config)
+	c := &clock{now: time.Now()}
+	s.now = c.Now
+	ts := &testServer{t: t, s: s, http: httptest.NewServer(s), clock: c}
+	t.Cleanup(func() {
+		ts.http.Close()
+		s.Close()
+	})
+	return ts
+}
+
+// do sends a request with the body encoded as JSON, if not nil, and decodes
+// the response into v, if not nil. It returns the status code.
+func (ts *testServer) do(method, path string, body, v interface{}) int {
+	ts.t.Helper()
+	var data []byte
+	if s, ok := body.(string); ok {
+		data = []byte(s)
+	} else if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.http.URL+path, bytes.NewReader(data))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// submit submits req and waits for the job to end.
+func (ts *testServer) submit(req JobRequest) JobStatus {
+	ts.t.Helper()
+	var status JobStatus
+	if code := ts.do(http.MethodPost, "/jobs", req, &status); code != http.StatusAccepted {
+		ts.t.Fatalf("POST /jobs: status %d", code)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.Status == StatusQueued || status.Status == StatusRunning {
+		if time.Now().After(deadline) {
+			ts.t.Fatalf("job %s still %s", status.ID, status.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if code := ts.do(http.MethodGet, "/jobs/"+status.ID, nil, &status); code != http.StatusOK {
+			ts.t.Fatalf("GET /jobs/%s: status %d", status.ID, code)
+		}
+	}
+	return status
+}
+
+func (ts *testServer) results(id, query string) ResultsPage {
+	ts.t.Helper()
+	var page ResultsPage
+	if code := ts.do(http.MethodGet, "/jobs/"+id+"/results"+query, nil, &page); code != http.StatusOK {
+		ts.t.Fatalf("GET /jobs/%s/results%s: status %d", id, query, code)
+	}
+	return page
+}
+
+func TestJobResults(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	status := ts.submit(JobRequest{Repo: testRepo(t)})
+	if status.Status != StatusDone {
+		t.Fatalf("job %s: %s", status.Status, status.Error)
+	}
+	all := ts.results(status.ID, "")
+	if all.Total != status.Results || len(all.Churns) != all.Total || all.Total == 0 {
+		t.Fatalf("%d churns of %d, want %d", len(all.Churns), all.Total, status.Results)
+	}
+	page := ts.results(status.ID, "?offset=2&limit=3")
+	if page.Total != all.Total || len(page.Churns) != 3 || page.Churns[0].CommitID != all.Churns[2].CommitID {
+		t.Errorf("page %d+%d of %d, want 3 churns from %s", page.Offset, len(page.Churns), page.Total,
+			all.Churns[2].CommitID)
+	}
+	if page := ts.results(status.ID, "?offset=1000"); len(page.Churns) != 0 {
+		t.Errorf("%d churns beyond the end, want none", len(page.Churns))
+	}
+	if code := ts.do(http.MethodGet, "/jobs/"+status.ID+"/results?limit=-1", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("negative limit: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var list []JobStatus
+	if code := ts.do(http.MethodGet, "/jobs", nil, &list); code != http.StatusOK || len(list) != 1 {
+		t.Errorf("GET /jobs: status %d, %d jobs, want 1", code, len(list))
+	}
+	if code := ts.do(http.MethodDelete, "/jobs/"+status.ID, nil, nil); code != http.StatusConflict {
+		t.Errorf("DELETE of a done job: status %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestJobOptions(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	dir := testRepo(t)
+	all := ts.results(ts.submit(JobRequest{Repo: dir}).ID, "")
+
+	// the commits are an hour apart, the oldest first
+	since, err := time.Parse("2006-01-02 15:04:05 -0700", all.Churns[5].Date[:25])
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := ts.submit(JobRequest{Repo: dir, Since: &since})
+	if want := len(all.Churns) - 5; status.Results != want {
+		t.Errorf("%d churns since %s, want %d", status.Results, since, want)
+	}
+	until := since.Add(-time.Minute)
+	if code := ts.do(http.MethodPost, "/jobs", JobRequest{Repo: dir, Since: &since, Until: &until}, nil); code != http.StatusBadRequest {
+		t.Errorf("Until before Since: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	page := ts.results(ts.submit(JobRequest{Repo: dir, Paths: []string{"nothing"}}).ID, "")
+	for _, churn := range page.Churns {
+		if len(churn.ChurnFiles) != 0 {
+			t.Errorf("%s: churn files %+v outside of the paths", churn.CommitID, churn.ChurnFiles)
+		}
+	}
+}
+
+// A repository is fetched again by the following jobs.
+func TestJobRefreshesRepo(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	dir := testRepo(t)
+	first := ts.submit(JobRequest{Repo: dir})
+
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "new", Email: "new@example.com", When: time.Now()}
+	if _, err := w.Commit("new file", &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	second := ts.submit(JobRequest{Repo: dir})
+	if second.Results != first.Results+1 {
+		t.Errorf("%d churns after a new commit, want %d", second.Results, first.Results+1)
+	}
+}
+
+func TestJobsExpire(t *testing.T) {
+	ts := newTestServer(t, Config{TTL: time.Hour, MaxJobs: 2})
+	dir := testRepo(t)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, ts.submit(JobRequest{Repo: dir}).ID)
+	}
+	if code := ts.do(http.MethodGet, "/jobs/"+ids[0], nil, nil); code != http.StatusNotFound {
+		t.Errorf("oldest job beyond MaxJobs: status %d, want %d", code, http.StatusNotFound)
+	}
+	if code := ts.do(http.MethodGet, "/jobs/"+ids[2], nil, nil); code != http.StatusOK {
+		t.Errorf("newest job: status %d, want %d", code, http.StatusOK)
+	}
+	ts.clock.add(2 * time.Hour)
+	var list []JobStatus
+	if ts.do(http.MethodGet, "/jobs", nil, &list); len(list) != 0 {
+		t.Errorf("%d jobs after their TTL, want none", len(list))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	tests := []struct {
+		method, path string
+		body         interface{}
+		code         int
+	}{
+		{http.MethodPost, "/jobs", "{", http.StatusBadRequest},
+		{http.MethodPost, "/jobs", JobRequest{}, http.StatusBadRequest},
+		{http.MethodGet, "/jobs/42", nil, http.StatusNotFound},
+		{http.MethodGet, "/jobs/42/results", nil, http.StatusNotFound},
+		{http.MethodDelete, "/jobs/42", nil, http.StatusNotFound},
+		{http.MethodGet, "/other", nil, http.StatusNotFound},
+		{http.MethodPut, "/jobs", nil, http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		var body struct{ Error string }
+		if code := ts.do(test.method, test.path, test.body, &body); code != test.code || body.Error == "" {
+			t.Errorf("%s %s: status %d %q, want %d with an error", test.method, test.path, code, body.Error,
+				test.code)
+		}
+	}
+
+	status := ts.submit(JobRequest{Repo: filepath.Join(os.TempDir(), "no-such-repo")})
+	if status.Status != StatusFailed || status.Error == "" {
+		t.Errorf("job on a missing repository %s %q, want failed", status.Status, status.Error)
+	}
+	var body struct{ Error string }
+	if code := ts.do(http.MethodGet, "/jobs/"+status.ID+"/results", nil, &body); code != http.StatusConflict {
+		t.Errorf("results of a failed job: status %d, want %d", code, http.StatusConflict)
+	}
+}
+
+// The repositories beyond the maximum are forgotten, the least recently used
+// first, but not while a job holds them.
+func TestRepoCacheEvicts(t *testing.T) {
+	c := newRepoCache(1)
+	a, b := testRepo(t), testRepo(t)
+	get := func(url string) *cachedRepo {
+		t.Helper()
+		cr, err := c.get(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cr
+	}
+	cached := func(want ...string) {
+		t.Helper()
+		var got []string
+		for url := range c.repos {
+			got = append(got, url)
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cached %q, want %q", got, want)
+		}
+	}
+
+	c.release(get(a))
+	c.release(get(b))
+	cached(b)
+
+	held := get(a)
+	c.release(get(b))
+	cached(a)
+	c.release(held)
+	cached(a)
+
+	missing := filepath.Join(a, "missing")
+	if _, err := c.get(context.Background(), missing); err == nil {
+		t.Fatalf("%s opened, want an error", missing)
+	}
+	cached(a)
+}
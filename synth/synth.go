@@ -0,0 +1,527 @@
+// Package synth builds deterministic git repositories of a given shape, along
+// with the ownership of their lines and the churn of their commits as expected
+// from the metrics package. The same seed and spec always give the same
+// repository, down to the commit hashes.
+package synth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Spec is the shape of a repository.
+type Spec struct {
+	Seed int64
+	// Authors is the number of authors committing.
+	Authors int
+	// Commits is the total number of commits, merges included.
+	Commits int
+	// Files is the number of files added over the history.
+	Files int
+	// Lines is the number of lines of the files when they are added.
+	Lines int
+	// MergeRatio is the probability that a commit starts a topic branch,
+	// merged back a few commits later.
+	MergeRatio float64
+	// RenameRatio is the probability that a commit renames a file.
+	RenameRatio float64
+	// RewriteRatio is the share of the lines of a file replaced by a commit
+	// modifying it.
+	RewriteRatio float64
+}
+
+// DefaultSpec is a small repository with some merges and renames.
+var DefaultSpec = Spec{
+	Seed:         1,
+	Authors:      4,
+	Commits:      100,
+	Files:        10,
+	Lines:        40,
+	MergeRatio:   0.1,
+	RenameRatio:  0.05,
+	RewriteRatio: 0.2,
+}
+
+// Result is a built repository and what is expected from it.
+type Result struct {
+	Repository *git.Repository `json:"-"`
+	Head       string
+	// Files maps the files at the head to the owner of each of their lines.
+	Files map[string][]ExpectedLine
+	// Churns is the churn of every commit but the merges, which have no
+	// churn, oldest first. The lines keep their owner across renames and
+	// merges.
+	Churns []metrics.Churn
+}
+
+// ExpectedLine is the commit, and its author, that last wrote a line.
+type ExpectedLine struct {
+	Author string
+	Commit string
+}
+
+// Authors returns the number of lines owned by each author at the head.
+func (r *Result) Authors() map[string]int {
+	result := make(map[string]int)
+	for _, lines := range r.Files {
+		for _, l := range lines {
+			result[l.Author]++
+		}
+	}
+	return result
+}
+
+// Build builds the repository described by spec in memory.
+func Build(spec Spec) (*Result, error) {
+	r, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return build(r, spec)
+}
+
+// BuildDir builds the repository described by spec in the directory dir,
+// which must not be a repository already, and checks out its head.
+func BuildDir(dir string, spec Spec) (*Result, error) {
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	result, err := build(r, spec)
+	if err != nil {
+		return nil, err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	err = w.Reset(&git.ResetOptions{Commit: plumbing.NewHash(result.Head), Mode: git.HardReset})
+	return result, err
+}
+
+func (s Spec) validate() error {
+	switch {
+	case s.Authors < 1:
+		return errors.New("synth: at least one author is needed")
+	case s.Commits < 1:
+		return errors.New("synth: at least one commit is needed")
+	case s.Files < 1:
+		return errors.New("synth: at least one file is needed")
+	case s.Lines < 1:
+		return errors.New("synth: files need at least one line")
+	case s.MergeRatio < 0 || s.MergeRatio > 1, s.RenameRatio < 0 || s.RenameRatio > 1,
+		s.RewriteRatio < 0 || s.RewriteRatio > 1:
+		return errors.New("synth: ratios must be between 0 and 1")
+	}
+	return nil
+}
+
+// epoch is the date of the first commit, the following ones are an hour
+// apart.
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// addFileRatio is the probability that a commit adds a file while there are
+// fewer than Spec.Files.
+const addFileRatio = 0.3
+
+type line struct {
+	text string
+	// origin is the index in builder.commits of the commit that wrote it.
+	origin int
+}
+
+// branch is the tip of a branch and its files. The line slices are never
+// modified in place so that branches can share them.
+type branch struct {
+	tip   int
+	files map[string][]line
+}
+
+func (b *branch) fork() *branch {
+	files := make(map[string][]line, len(b.files))
+	for name, lines := range b.files {
+		files[name] = lines
+	}
+	return &branch{tip: b.tip, files: files}
+}
+
+// paths returns the files of the branch, sorted so that random picks are
+// deterministic.
+func (b *branch) paths() []string {
+	result := make([]string, 0, len(b.files))
+	for name := range b.files {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+type commit struct {
+	hash    plumbing.Hash
+	author  string
+	parents []string
+	change
+}
+
+// change is what a commit changes, as expected from its churn.
+type change struct {
+	merge bool
+	// churn is the churn of the file modified, if any.
+	churn *metrics.ChurnFile
+	stats metrics.CommitStats
+}
+
+// files returns the churn files of the commit.
+func (c *commit) files() []metrics.ChurnFile {
+	if c.churn == nil {
+		return []metrics.ChurnFile{}
+	}
+	return []metrics.ChurnFile{*c.churn}
+}
+
+type builder struct {
+	spec    Spec
+	rnd     *rand.Rand
+	r       *git.Repository
+	commits []*commit
+	added   int
+	names   int
+	seq     int
+}
+
+func build(r *git.Repository, spec Spec) (*Result, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	b := &builder{spec: spec, rnd: rand.New(rand.NewSource(spec.Seed)), r: r}
+	master := &branch{tip: -1, files: make(map[string][]line)}
+	for len(b.commits) < spec.Commits {
+		var err error
+		left := spec.Commits - len(b.commits)
+		if len(master.files) >= 2 && left >= 3 && b.rnd.Float64() < spec.MergeRatio {
+			err = b.topic(master, left)
+		} else {
+			err = b.change(master, nil, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	head := b.commits[master.tip].hash
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, head)); err != nil {
+		return nil, err
+	}
+	return b.result(master)
+}
+
+// topic forks a topic branch from master, commits on both and merges the
+// topic branch back into master. The topic branch only modifies a subset of
+// the files, and master the other ones, so that the merge has no conflicts.
+func (b *builder) topic(master *branch, left int) error {
+	paths := master.paths()
+	b.rnd.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+	n := 1 + b.rnd.Intn(len(paths)/2)
+	topicFiles := make(map[string]bool)
+	for _, p := range paths[:n] {
+		topicFiles[p] = true
+	}
+
+	topic := master.fork()
+	commits := 1 + b.rnd.Intn(3)
+	if commits > left-2 {
+		commits = left - 2
+	}
+	for i := 0; i < commits; i++ {
+		if err := b.change(topic, func(p string) bool { return topicFiles[p] }, false); err != nil {
+			return err
+		}
+	}
+	if err := b.change(master, func(p string) bool { return !topicFiles[p] }, false); err != nil {
+		return err
+	}
+
+	for p := range topicFiles {
+		master.files[p] = topic.files[p]
+	}
+	author := b.rnd.Intn(b.spec.Authors)
+	msg := fmt.Sprintf("Merge branch 'topic-%d'", len(b.commits))
+	return b.commit(master, author, msg, change{merge: true}, topic.tip)
+}
+
+// change commits a change on the branch: it adds a file, renames one or
+// modifies one of the files allowed.
+func (b *builder) change(br *branch, allowed func(string) bool, canRename bool) error {
+	author := b.rnd.Intn(b.spec.Authors)
+	var candidates []string
+	for _, p := range br.paths() {
+		if allowed == nil || allowed(p) {
+			candidates = append(candidates, p)
+		}
+	}
+	canAdd := b.added < b.spec.Files && (allowed == nil || len(candidates) == 0)
+	switch {
+	case len(candidates) == 0 || canAdd && b.rnd.Float64() < addFileRatio:
+		path := b.newPath()
+		lines := make([]line, b.spec.Lines)
+		for i := range lines {
+			lines[i] = b.newLine()
+		}
+		br.files[path] = lines
+		b.added++
+		stats := metrics.CommitStats{FilesChanged: 1, FilesAdded: 1, Insertions: len(lines)}
+		return b.commit(br, author, "Add "+path, change{stats: stats})
+	case canRename && b.rnd.Float64() < b.spec.RenameRatio:
+		from := candidates[b.rnd.Intn(len(candidates))]
+		to := b.newPath()
+		br.files[to] = br.files[from]
+		delete(br.files, from)
+		stats := metrics.CommitStats{FilesChanged: 1, FilesRenamed: 1}
+		return b.commit(br, author, "Rename "+from+" to "+to, change{stats: stats})
+	default:
+		path := candidates[b.rnd.Intn(len(candidates))]
+		return b.commit(br, author, "Modify "+path, b.rewrite(br, path, author))
+	}
+}
+
+// rewrite replaces a chunk of the lines of path by new lines written by
+// author, and returns the change.
+func (b *builder) rewrite(br *branch, path string, author int) change {
+	old := br.files[path]
+	n := int(math.Round(b.spec.RewriteRatio * float64(len(old))))
+	if n < 1 {
+		n = 1
+	}
+	offset := b.rnd.Intn(len(old) - n + 1)
+	added := n + b.rnd.Intn(3) - 1
+	if added < 1 {
+		added = 1
+	}
+
+	lines := make([]line, 0, len(old)-n+added)
+	lines = append(lines, old[:offset]...)
+	for i := 0; i < added; i++ {
+		lines = append(lines, b.newLine())
+	}
+	lines = append(lines, old[offset+n:]...)
+	br.files[path] = lines
+
+	churn := &metrics.ChurnFile{FileName: path}
+	email := authorEmail(author)
+	for i := offset; i < offset+n; i++ {
+		owner := b.commits[old[i].origin].author
+		if owner == email {
+			churn.SelfChurn = append(churn.SelfChurn, i+1)
+			continue
+		}
+		if churn.InteractiveChurn == nil {
+			churn.InteractiveChurn = make(map[string][]int)
+		}
+		churn.InteractiveChurn[owner] = append(churn.InteractiveChurn[owner], i+1)
+	}
+
+	stats := metrics.CommitStats{
+		FilesChanged:   1,
+		Insertions:     added,
+		Deletions:      n,
+		SelfChurn:      len(churn.SelfChurn),
+		TouchedAuthors: len(churn.InteractiveChurn),
+	}
+	for _, lines := range churn.InteractiveChurn {
+		stats.InteractiveChurn += len(lines)
+	}
+	if stats.SelfChurn != 0 {
+		stats.TouchedAuthors++
+	}
+	return change{churn: churn, stats: stats}
+}
+
+func (b *builder) newPath() string {
+	dirs := b.spec.Files/5 + 1
+	path := fmt.Sprintf("dir%d/file%d.txt", b.names%dirs, b.names)
+	b.names++
+	return path
+}
+
+// newLine returns a line written by the next commit. Every line is unique so
+// that the diffs are unambiguous.
+func (b *builder) newLine() line {
+	b.seq++
+	return line{text: fmt.Sprintf("line %d", b.seq), origin: len(b.commits)}
+}
+
+func authorName(i int) string {
+	return fmt.Sprintf("Author %d", i)
+}
+
+func authorEmail(i int) string {
+	return fmt.Sprintf("author%d@example.com", i)
+}
+
+// commit commits the files of the branch on top of its tip, and of the other
+// parents given.
+func (b *builder) commit(br *branch, author int, msg string, ch change, parents ...int) error {
+	tree, err := b.writeTree(br.files)
+	if err != nil {
+		return err
+	}
+	when := epoch.Add(time.Duration(len(b.commits)) * time.Hour)
+	sig := object.Signature{Name: authorName(author), Email: authorEmail(author), When: when}
+	c := &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   msg + "\n",
+		TreeHash:  tree,
+	}
+	if br.tip != -1 {
+		c.ParentHashes = append(c.ParentHashes, b.commits[br.tip].hash)
+	}
+	for _, p := range parents {
+		c.ParentHashes = append(c.ParentHashes, b.commits[p].hash)
+	}
+	hash, err := b.store(c)
+	if err != nil {
+		return err
+	}
+	info := &commit{hash: hash, author: sig.Email, parents: []string{}, change: ch}
+	for _, p := range c.ParentHashes {
+		info.parents = append(info.parents, p.String())
+	}
+	br.tip = len(b.commits)
+	b.commits = append(b.commits, info)
+	return nil
+}
+
+// writeTree stores the blobs and the trees of files and returns the hash of
+// the root tree.
+func (b *builder) writeTree(files map[string][]line) (plumbing.Hash, error) {
+	root := newDir()
+	for path, lines := range files {
+		var text strings.Builder
+		for _, l := range lines {
+			text.WriteString(l.text + "\n")
+		}
+		obj := b.r.Storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if _, err := w.Write([]byte(text.String())); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		w.Close()
+		hash, err := b.r.Storer.SetEncodedObject(obj)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		root.add(strings.Split(path, "/"), hash)
+	}
+	return root.store(b)
+}
+
+type dir struct {
+	blobs map[string]plumbing.Hash
+	dirs  map[string]*dir
+}
+
+func newDir() *dir {
+	return &dir{blobs: make(map[string]plumbing.Hash), dirs: make(map[string]*dir)}
+}
+
+func (d *dir) add(path []string, hash plumbing.Hash) {
+	if len(path) == 1 {
+		d.blobs[path[0]] = hash
+		return
+	}
+	sub, ok := d.dirs[path[0]]
+	if !ok {
+		sub = newDir()
+		d.dirs[path[0]] = sub
+	}
+	sub.add(path[1:], hash)
+}
+
+func (d *dir) store(b *builder) (plumbing.Hash, error) {
+	t := &object.Tree{}
+	for name, hash := range d.blobs {
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+	}
+	for name, sub := range d.dirs {
+		hash, err := sub.store(b)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+	// git sorts the entries by name, the names of directories ending with
+	// a slash
+	sort.Slice(t.Entries, func(i, j int) bool {
+		return entryKey(t.Entries[i]) < entryKey(t.Entries[j])
+	})
+	return b.store(t)
+}
+
+func entryKey(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+func (b *builder) store(o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := b.r.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return b.r.Storer.SetEncodedObject(obj)
+}
+
+func (b *builder) result(master *branch) (*Result, error) {
+	result := &Result{
+		Repository: b.r,
+		Head:       b.commits[master.tip].hash.String(),
+		Files:      make(map[string][]ExpectedLine, len(master.files)),
+	}
+	for path, lines := range master.files {
+		expected := make([]ExpectedLine, len(lines))
+		for i, l := range lines {
+			c := b.commits[l.origin]
+			expected[i] = ExpectedLine{Author: c.author, Commit: c.hash.String()}
+		}
+		result.Files[path] = expected
+	}
+	for _, c := range b.commits {
+		if c.merge {
+			continue
+		}
+		// the churn dates are formatted the way they are decoded
+		obj, err := b.r.CommitObject(c.hash)
+		if err != nil {
+			return nil, err
+		}
+		result.Churns = append(result.Churns, metrics.Churn{
+			CommitID:      c.hash.String(),
+			CommitAuthor:  c.author,
+			Date:          obj.Author.When.String(),
+			CommitMessage: obj.Message,
+			ChurnFiles:    c.files(),
+			Parents:       c.parents,
+			Stats:         c.stats,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package synth_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ashishgalagali/go-git-churn/metrics"
+	"github.com/ashishgalagali/go-git-churn/synth"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var seeds = []int64{1, 2, 3, 42}
+
+func specOf(seed int64) synth.Spec {
+	spec := synth.DefaultSpec
+	spec.Seed = seed
+	return spec
+}
+
+func build(t testing.TB, spec synth.Spec) (*synth.Result, *object.Commit) {
+	t.Helper()
+	result, err := synth.Build(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := result.Repository.CommitObject(plumbing.NewHash(result.Head))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, head
+}
+
+func TestBuildIsDeterministic(t *testing.T) {
+	a, _ := build(t, synth.DefaultSpec)
+	b, _ := build(t, synth.DefaultSpec)
+	if a.Head != b.Head {
+		t.Errorf("heads %s and %s, want the same", a.Head, b.Head)
+	}
+}
+
+// The churn computed by the metrics package is the one synth expects, renames
+// and merges included.
+func TestChurnsGolden(t *testing.T) {
+	for _, seed := range seeds {
+		result, head := build(t, specOf(seed))
+		churns, err := metrics.Churns(context.Background(), head, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]metrics.Churn, len(churns))
+		for _, churn := range churns {
+			got[churn.CommitID] = churn
+			if len(churn.Parents) > 1 && len(churn.ChurnFiles) != 0 {
+				t.Errorf("seed %d: merge %s has churn files %+v", seed, churn.CommitID[:8], churn.ChurnFiles)
+			}
+		}
+		for _, want := range result.Churns {
+			churn, ok := got[want.CommitID]
+			if !ok {
+				t.Errorf("seed %d: no churn for %s", seed, want.CommitID)
+				continue
+			}
+			if churn.Stats != want.Stats {
+				t.Errorf("seed %d: %s %q: stats %+v, want %+v", seed, want.CommitID[:8], want.CommitMessage,
+					churn.Stats, want.Stats)
+			}
+			if !reflect.DeepEqual(churn.ChurnFiles, want.ChurnFiles) {
+				t.Errorf("seed %d: %s %q: churn files %+v, want %+v", seed, want.CommitID[:8],
+					want.CommitMessage, churn.ChurnFiles, want.ChurnFiles)
+			}
+		}
+	}
+}
+
+// The owners computed by the metrics package are the ones synth expects.
+func TestOwnershipGolden(t *testing.T) {
+	for _, seed := range seeds {
+		result, head := build(t, specOf(seed))
+		ownership, err := metrics.ComputeOwnership(context.Background(), head, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]map[string]int)
+		for _, f := range ownership.Files {
+			got[f.Path] = make(map[string]int)
+			for _, a := range f.Authors {
+				got[f.Path][a.Author] = a.Lines
+			}
+		}
+		want := make(map[string]map[string]int)
+		for name, lines := range result.Files {
+			want[name] = make(map[string]int)
+			for _, l := range lines {
+				want[name][l.Author]++
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %d: owners %v, want %v", seed, got, want)
+		}
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := synth.Build(synth.DefaultSpec); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChurns(b *testing.B) {
+	_, head := build(b, synth.DefaultSpec)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := metrics.Churns(context.Background(), head, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOwnership(b *testing.B) {
+	_, head := build(b, synth.DefaultSpec)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := metrics.ComputeOwnership(context.Background(), head, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}